@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+//AccessCheck type
+type AccessCheck string
+
+const (
+	//AccessCheckSkip constant, the access is not checked
+	AccessCheckSkip AccessCheck = "skip"
+	//AccessCheckWarn constant, the permissions missing are logged
+	AccessCheckWarn AccessCheck = "warn"
+	//AccessCheckFail constant, the watch fails if any permission missing
+	AccessCheckFail AccessCheck = "fail"
+)
+
+// accessCheckVerbs are required by the list and watch of the watches
+var accessCheckVerbs = []string{"list", "watch"}
+
+var selfSubjectAccessReviewResource = schema.GroupVersionResource{Group: "authorization.k8s.io", Version: "v1", Resource: "selfsubjectaccessreviews"}
+
+// checkAccess reviews the verbs of the resource in the namespace (empty for all namespaces) by SelfSubjectAccessReview,
+// the permissions denied are logged or returned as error by AccessCheck. Failures of the reviews are only logged.
+func (i *informer) checkAccess(resource schema.GroupVersionResource, namespace string) error {
+	if i.AccessCheck == "" || i.AccessCheck == AccessCheckSkip {
+		return nil
+	}
+	denied := []string{}
+	for _, verb := range accessCheckVerbs {
+		allowed, reason, err := i.reviewAccess(resource, namespace, verb)
+		if err != nil {
+			i.Logger.Error("failed to review access", err, "resource", resource.String(), "namespace", namespace, "verb", verb)
+			continue
+		}
+		if !allowed {
+			if reason != "" {
+				verb = fmt.Sprintf("%s (%s)", verb, reason)
+			}
+			denied = append(denied, verb)
+		}
+	}
+	if len(denied) == 0 {
+		return nil
+	}
+	scope := "in all namespaces"
+	if namespace != metav1.NamespaceAll {
+		scope = fmt.Sprintf("in namespace %q", namespace)
+	}
+	err := fmt.Errorf("permission denied to %s %s %s", strings.Join(denied, ", "), resource.GroupResource().String(), scope)
+	if i.AccessCheck == AccessCheckWarn {
+		i.Logger.Info("missing permission", "reason", err.Error())
+		return nil
+	}
+	return err
+}
+
+func (i *informer) reviewAccess(resource schema.GroupVersionResource, namespace string, verb string) (bool, string, error) {
+	review := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "authorization.k8s.io/v1",
+		"kind":       "SelfSubjectAccessReview",
+		"spec": map[string]interface{}{
+			"resourceAttributes": map[string]interface{}{
+				"namespace": namespace,
+				"verb":      verb,
+				"group":     resource.Group,
+				"version":   resource.Version,
+				"resource":  resource.Resource,
+			},
+		},
+	}}
+	result, err := i.dynamicClient.Resource(selfSubjectAccessReviewResource).Create(review, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", err
+	}
+	allowed, _, _ := unstructured.NestedBool(result.Object, "status", "allowed")
+	reason, _, _ := unstructured.NestedString(result.Object, "status", "reason")
+	return allowed, reason, nil
+}
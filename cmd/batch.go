@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+)
+
+// dispatch passes the items of the queue to the batch workers until the queue is shut down and drained
+func (i *informer) dispatch(ctx context.Context, items chan<- eventKey) {
+	defer close(items)
+	for {
+		item, quit := i.queue.Get()
+		if quit {
+			return
+		}
+		select {
+		case items <- item.(eventKey):
+		case <-ctx.Done():
+			i.queue.Done(item)
+			return
+		}
+	}
+}
+
+// processNextBatch handles the items received within BatchSize and BatchTimeout by BatchHandler,
+// waiting is the events of the worker waiting for the objects of its last batch, they start the next batch
+func (i *informer) processNextBatch(ctx context.Context, items <-chan eventKey, waiting *[]eventKey) bool {
+	batch := []*queuedEvent{}
+	// done releases the object of the item done, the event waiting for the object is handled in the next batch
+	done := func(key objectKey) {
+		if item, ok := i.keyOrder.next(key); ok {
+			*waiting = append(*waiting, item)
+		}
+	}
+	add := func(item eventKey, started bool) {
+		// the events of the same object are handled in order by the batch workers,
+		// the event waits for the event of the object in this or another batch
+		if !started && !i.keyOrder.start(item) {
+			return
+		}
+		if e := i.resolve(ctx, item); e != nil {
+			batch = append(batch, e)
+			return
+		}
+		i.queue.Done(item)
+		done(item.objectKey)
+	}
+	var item eventKey
+	ok := true
+	if started := *waiting; len(started) > 0 {
+		*waiting = nil
+		for _, item := range started {
+			add(item, true)
+		}
+	} else {
+		select {
+		case item, ok = <-items:
+			if !ok {
+				return false
+			}
+		case <-ctx.Done():
+			return false
+		}
+		add(item, false)
+	}
+	timeout := time.NewTimer(i.BatchTimeout)
+	defer timeout.Stop()
+collect:
+	for len(batch) < i.BatchSize {
+		select {
+		case item, ok = <-items:
+			if !ok {
+				break collect
+			}
+			add(item, false)
+		case <-timeout.C:
+			break collect
+		case <-ctx.Done():
+			break collect
+		}
+	}
+	err := i.handleBatch(ctx, batch)
+	for _, e := range batch {
+		if e.err != nil {
+			i.complete(ctx, e, e.err)
+		} else if e.matched {
+			i.complete(ctx, e, err)
+		} else {
+			i.complete(ctx, e, nil)
+		}
+		i.queue.Done(e.eventKey)
+		done(e.objectKey)
+	}
+	return (ok || len(*waiting) > 0) && ctx.Err() == nil
+}
+
+// handleBatch calls BatchHandler with the matched events of the batch
+func (i *informer) handleBatch(ctx context.Context, batch []*queuedEvent) (err error) {
+	events := []Event{}
+	for _, e := range batch {
+		if e.err == nil && e.matched {
+			events = append(events, Event{Type: e.event, Object: e.obj, OldObject: e.old, Cluster: i.cluster, Watch: e.watch.name, Retries: e.numRetries, Synced: e.synced, LastAppliedDiff: e.watch.lastAppliedDiff(e.event, e.obj, e.old)})
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if i.DryRun {
+		for _, e := range batch {
+			if e.err == nil && e.matched {
+				i.dryRun(e)
+			}
+		}
+		return nil
+	}
+	if i.Tracer != nil {
+		var span Span
+		ctx, span = i.startSpan(ctx, "handle batch", map[string]string{"events": strconv.Itoa(len(events))})
+		defer span.End()
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+			}
+		}()
+	}
+	start := time.Now()
+	err = i.callWithTimeout(ctx, func(ctx context.Context) error {
+		return i.BatchHandler(ctx, events)
+	})
+	for _, event := range events {
+		if err != nil {
+			break
+		}
+		err = i.emit(ctx, event)
+	}
+	elapsed := time.Since(start).Seconds()
+	for _, e := range batch {
+		if e.err == nil && e.matched {
+			i.metrics.handlerDuration.WithLabelValues(string(e.event), e.watch.name).Observe(elapsed)
+			i.metrics.events.WithLabelValues(string(e.event), e.watch.name).Inc()
+			if err != nil {
+				i.metrics.handlerErrors.WithLabelValues(string(e.event), e.watch.name).Inc()
+			}
+		}
+	}
+	return err
+}
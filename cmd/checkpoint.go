@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xiaopal/kube-informer/pkg/logging"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/tools/cache"
+)
+
+// checkpoint records the resourceVersions of the objects handled by each watch (keyed by informerWatch.checkpointKey),
+// the nil checkpoint records nothing
+type checkpoint struct {
+	sync.Mutex
+	file    string
+	watches map[string]map[string]string
+	// claimed are the watches run since loaded, the records of the others are not saved
+	claimed map[string]bool
+	dirty   bool
+}
+
+func loadCheckpoint(file string) (*checkpoint, error) {
+	c := &checkpoint{file: file, watches: map[string]map[string]string{}, claimed: map[string]bool{}}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.watches); err != nil {
+		return nil, err
+	}
+	// saved once to prune the records of the watches not run
+	c.dirty = len(c.watches) > 0
+	return c, nil
+}
+
+// claim marks the records of the watch run, the records of watches not run since loaded
+// (eg. stopped, or with another selector) are pruned when saved
+func (c *checkpoint) claim(watch string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.claimed[watch] = true
+}
+
+// handled returns true if the object of the resourceVersion is recorded
+func (c *checkpoint) handled(watch string, key string, resourceVersion string) bool {
+	if c == nil {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+	recorded, ok := c.watches[watch][key]
+	return ok && recorded == resourceVersion
+}
+
+// keys returns the recorded objects of the watch
+func (c *checkpoint) keys(watch string) []string {
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	keys := make([]string, 0, len(c.watches[watch]))
+	for key := range c.watches[watch] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// record the resourceVersion of the object, or removes the object if obj is nil
+func (c *checkpoint) record(watch string, key string, obj *unstructured.Unstructured) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	objects, ok := c.watches[watch]
+	if obj == nil {
+		if _, ok := objects[key]; ok {
+			delete(objects, key)
+			c.dirty = true
+		}
+		return
+	}
+	if !ok {
+		objects = map[string]string{}
+		c.watches[watch] = objects
+	}
+	if objects[key] != obj.GetResourceVersion() {
+		objects[key] = obj.GetResourceVersion()
+		c.dirty = true
+	}
+}
+
+// save writes the checkpoint file if changed
+func (c *checkpoint) save() error {
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	if !c.dirty {
+		return nil
+	}
+	watches := map[string]map[string]string{}
+	for watch, objects := range c.watches {
+		if c.claimed[watch] {
+			watches[watch] = objects
+		}
+	}
+	data, err := json.Marshal(watches)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(c.file), filepath.Base(c.file)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.file); err != nil {
+		return err
+	}
+	c.dirty = false
+	return nil
+}
+
+// run saves the checkpoint every interval until ctx done
+func (c *checkpoint) run(ctx context.Context, interval time.Duration, log logging.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.save(); err != nil {
+				log.Error("failed to save checkpoint", err, "file", c.file)
+			}
+		}
+	}
+}
+
+// replayDeleted enqueues the delete events of the recorded objects not found once the watch is synced,
+// the last known state is the object of the recorded key
+func (w *informerWatch) replayDeleted() {
+	if w.informer.IgnoreDeletes {
+		return
+	}
+	for _, key := range w.informer.checkpoint.keys(w.checkpointKey) {
+		if _, exists, err := w.watcher.GetIndexer().GetByKey(key); err != nil || exists {
+			continue
+		}
+		namespace, name, err := cache.SplitMetaNamespaceKey(key)
+		if err != nil {
+			continue
+		}
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(w.apiVersion)
+		obj.SetKind(w.kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		if w.pushed(EventDelete, key, obj, nil, false) {
+			continue
+		}
+		w.informer.deletedObjects.put(objectKey{w.index, key}, obj)
+		w.informer.enqueue(eventKey{objectKey{w.index, key}, EventDelete, false})
+	}
+}
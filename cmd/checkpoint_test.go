@@ -0,0 +1,65 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xiaopal/kube-informer/pkg/logging"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCheckpointKeyOfWatches(t *testing.T) {
+	mapper := newTestRESTMapper()
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Event"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "events.k8s.io", Version: "v1", Kind: "Event"}, meta.RESTScopeNamespace)
+	i := NewInformerWithClients(newTestDynamicClient(t), mapper, InformerOpts{Logger: logging.NewTextLogger(ioutil.Discard, "test")}).(*informer)
+	core, err := i.newWatch("v1", "Event", "default", WatchOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, err := i.newWatch("events.k8s.io/v1", "Event", "default", WatchOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the same name of the watches of both groups
+	if core.checkpointKey == events.checkpointKey {
+		t.Errorf("checkpoint key %q of both groups", core.checkpointKey)
+	}
+	if expected := "/v1/events default  "; core.checkpointKey != expected {
+		t.Errorf("checkpoint key %q, expected %q", core.checkpointKey, expected)
+	}
+}
+
+func TestCheckpointPrunesWatchesNotRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "informer-checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "checkpoint.json")
+	if err := ioutil.WriteFile(file, []byte(`{"/v1/configmaps default  ":{"default/cm-1":"1"},"/v1/configmaps default app=old ":{"default/cm-2":"2"}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := loadCheckpoint(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.claim("/v1/configmaps default  ")
+	// the records of the watches not run yet are kept until saved
+	if keys := c.keys("/v1/configmaps default app=old "); len(keys) != 1 {
+		t.Errorf("%d records of the watch not run, expected 1", len(keys))
+	}
+	if err := c.save(); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = loadCheckpoint(file); err != nil {
+		t.Fatal(err)
+	}
+	if expected := map[string]map[string]string{"/v1/configmaps default  ": {"default/cm-1": "1"}}; !reflect.DeepEqual(c.watches, expected) {
+		t.Errorf("saved %v, expected %v", c.watches, expected)
+	}
+}
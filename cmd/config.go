@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+type watchConfig struct {
+	APIVersion    string          `json:"apiVersion"`
+	Kind          string          `json:"kind"`
+	Namespace     string          `json:"namespace,omitempty"`
+	Name          string          `json:"name,omitempty"`
+	LabelSelector string          `json:"labelSelector,omitempty"`
+	FieldSelector string          `json:"fieldSelector,omitempty"`
+	Resync        metav1.Duration `json:"resync,omitempty"`
+	// WatchTimeout closes the watch periodically to relist from apiserver
+	WatchTimeout metav1.Duration `json:"watchTimeout,omitempty"`
+	// ExcludeNamespaces skips events of objects in these namespaces
+	ExcludeNamespaces []string `json:"excludeNamespaces,omitempty"`
+	// ListChunkSize lists objects in chunks on initial sync and relist
+	ListChunkSize int64 `json:"listChunkSize,omitempty"`
+	// EventRate limits the events of the watch handled per second
+	EventRate  float64 `json:"eventRate,omitempty"`
+	EventBurst int     `json:"eventBurst,omitempty"`
+	// MaxConcurrent limits the events of the watch handled concurrently by the workers
+	MaxConcurrent int `json:"maxConcurrent,omitempty"`
+	// Owner only handles objects owned directly by the owner
+	Owner *OwnerFilter `json:"owner,omitempty"`
+	// Transition only handles objects transitioning into the value at the path
+	Transition *TransitionFilter `json:"transition,omitempty"`
+	// GenerationChangesOnly skips updates unless metadata.generation changed
+	GenerationChangesOnly bool `json:"generationChangesOnly,omitempty"`
+	// StatusChangesOnly skips updates unless status changed
+	StatusChangesOnly bool `json:"statusChangesOnly,omitempty"`
+	// DiffLastApplied passes the diff of the last-applied-configuration annotation on updates
+	DiffLastApplied bool `json:"diffLastApplied,omitempty"`
+	// AnnotationMatch only handles objects with all the annotations, empty values match any values
+	AnnotationMatch map[string]string `json:"annotationMatch,omitempty"`
+	// AnnotationExclude skips objects with any of the annotations, empty values match any values
+	AnnotationExclude map[string]string `json:"annotationExclude,omitempty"`
+}
+
+type informerConfig struct {
+	MaxRetries *int `json:"maxRetries,omitempty"`
+	Workers    *int `json:"workers,omitempty"`
+	// SyncTimeout fails unless the caches of all watches are synced within the duration
+	SyncTimeout *metav1.Duration `json:"syncTimeout,omitempty"`
+	// PartialSync handles the watches synced within SyncTimeout instead of failing
+	PartialSync *bool         `json:"partialSync,omitempty"`
+	Watches     []watchConfig `json:"watches,omitempty"`
+}
+
+func loadConfig(path string) (*informerConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+	config := &informerConfig{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+	return config, nil
+}
+
+func (c *informerConfig) validate() error {
+	if c.Workers != nil && *c.Workers < 1 {
+		return fmt.Errorf("workers must be greater than zero")
+	}
+	if c.SyncTimeout != nil && c.SyncTimeout.Duration < 0 {
+		return fmt.Errorf("syncTimeout must not be negative")
+	}
+	for index, watch := range c.Watches {
+		if err := watch.validate(); err != nil {
+			return fmt.Errorf("invalid watch #%d: %v", index, err)
+		}
+	}
+	return nil
+}
+
+func (w *watchConfig) validate() error {
+	if w.APIVersion == "" {
+		return fmt.Errorf("apiVersion required")
+	}
+	if _, err := schema.ParseGroupVersion(w.APIVersion); err != nil {
+		return fmt.Errorf("failed to parse apiVersion: %v", err)
+	}
+	if w.Kind == "" {
+		return fmt.Errorf("kind required")
+	}
+	if _, err := labels.Parse(w.LabelSelector); err != nil {
+		return fmt.Errorf("failed to parse labelSelector: %v", err)
+	}
+	if _, err := fields.ParseSelector(w.FieldSelector); err != nil {
+		return fmt.Errorf("failed to parse fieldSelector: %v", err)
+	}
+	if w.EventRate < 0 || w.EventBurst < 0 {
+		return fmt.Errorf("eventRate and eventBurst must not be negative")
+	}
+	if w.MaxConcurrent < 0 {
+		return fmt.Errorf("maxConcurrent must not be negative")
+	}
+	if w.ListChunkSize < 0 {
+		return fmt.Errorf("listChunkSize must not be negative")
+	}
+	if w.Resync.Duration < 0 {
+		return fmt.Errorf("resync must not be negative")
+	}
+	if w.WatchTimeout.Duration < 0 {
+		return fmt.Errorf("watchTimeout must not be negative")
+	}
+	if w.Transition != nil {
+		if err := w.Transition.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w watchConfig) watchOpts() WatchOpts {
+	return WatchOpts{
+		Name:                  w.Name,
+		LabelSelector:         w.LabelSelector,
+		FieldSelector:         w.FieldSelector,
+		Resync:                w.Resync.Duration,
+		WatchTimeout:          w.WatchTimeout.Duration,
+		ExcludeNamespaces:     w.ExcludeNamespaces,
+		ListChunkSize:         w.ListChunkSize,
+		Owner:                 w.Owner,
+		Transition:            w.Transition,
+		AnnotationMatch:       w.AnnotationMatch,
+		AnnotationExclude:     w.AnnotationExclude,
+		GenerationChangesOnly: w.GenerationChangesOnly,
+		StatusChangesOnly:     w.StatusChangesOnly,
+		DiffLastApplied:       w.DiffLastApplied,
+		EventRate:             w.EventRate,
+		EventBurst:            w.EventBurst,
+		MaxConcurrent:         w.MaxConcurrent,
+	}
+}
+
+func (w watchConfig) String() string {
+	return fmt.Sprintf("apiVersion=%s,kind=%s", w.APIVersion, w.Kind)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+func writeConfig(t *testing.T, dir string, data string) string {
+	path := filepath.Join(dir, "informer.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "informer-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config, err := loadConfig(writeConfig(t, dir, `
+maxRetries: 5
+workers: 2
+syncTimeout: 5m
+watches:
+- apiVersion: v1
+  kind: ConfigMap
+- apiVersion: v1
+  kind: Pod
+  namespace: kube-system
+  labelSelector: k8s-app=kube-dns
+  resync: 10m
+  maxConcurrent: 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if *config.MaxRetries != 5 || *config.Workers != 2 || config.SyncTimeout.Duration != 5*time.Minute || len(config.Watches) != 2 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+	if watch := config.Watches[1]; watch.Namespace != "kube-system" || watch.LabelSelector != "k8s-app=kube-dns" ||
+		watch.Resync.Duration != 10*time.Minute || watch.MaxConcurrent != 2 {
+		t.Fatalf("unexpected watch %+v", watch)
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadConfig(writeConfig(t, dir, string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, config) {
+		t.Errorf("loaded %+v, expected %+v", loaded, config)
+	}
+}
+
+func TestConfigInvalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "informer-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// a typo in any watch fails before watching
+	config, err := loadConfig(writeConfig(t, dir, `
+watches:
+- apiVersion: v1
+  kind: ConfigMap
+- apiVersion: v1
+  kind: Pod
+  labelSelector: k8s-app in (kube-dns
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.validate(); err == nil {
+		t.Error("invalid labelSelector validated")
+	}
+	if _, err := loadConfig(writeConfig(t, dir, `
+watches:
+- apiVersion: v1
+  kindd: ConfigMap
+`)); err == nil {
+		t.Error("unknown field loaded")
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+// crdResync handles the CRDs again periodically, eg. to retry the watches failed
+const crdResync = 10 * time.Minute
+
+// crdDiscovery watches the CustomResourceDefinitions and adds a watch to the informer for the custom resources of each CRD
+// in the groups matching the patterns, the watches are replaced if the version changed and stopped if the CRD is deleted
+type crdDiscovery struct {
+	informer Informer
+	groups   []string
+	template watchConfig
+	lock     sync.Mutex
+	watches  map[string]configWatch
+}
+
+func newCRDDiscovery(informer Informer, groups []string, template watchConfig) *crdDiscovery {
+	return &crdDiscovery{informer: informer, groups: groups, template: template, watches: map[string]configWatch{}}
+}
+
+// run watches the CRDs until ctx is done
+func (d *crdDiscovery) run(ctx context.Context, kubeConfig *rest.Config) error {
+	crds := NewInformer(kubeConfig, InformerOpts{
+		Handler:    d.handle,
+		MaxRetries: 5,
+		Logger:     logger,
+	})
+	// the version preferred by the server, v1 or v1beta1
+	if err := crds.Watch("apiextensions.k8s.io/", "CustomResourceDefinition", "", WatchOpts{Resync: crdResync}); err != nil {
+		return err
+	}
+	return crds.Run(ctx)
+}
+
+func (d *crdDiscovery) matches(group string) bool {
+	for _, pattern := range d.groups {
+		if ok, _ := path.Match(pattern, group); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *crdDiscovery) handle(ctx context.Context, event EventType, crd *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	watch := d.template
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	watch.Kind, _, _ = unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	if event != EventDelete && d.matches(group) && crdEstablished(crd) {
+		if version := crdVersion(crd); version != "" {
+			watch.APIVersion = group + "/" + version
+		}
+	}
+	name := crd.GetName()
+	running, ok := d.watches[name]
+	if ok && running.APIVersion == watch.APIVersion && running.Kind == watch.Kind {
+		return nil
+	}
+	if ok {
+		d.stop(running)
+		delete(d.watches, name)
+		logger.Info("stopped watch of custom resources", "crd", name, "watch", running.String())
+	}
+	if watch.APIVersion == "" {
+		return nil
+	}
+	if index := d.watched(watch); index >= 0 {
+		// eg. declared by --watch, not to handle the events twice
+		logger.Info("custom resources already watched", "crd", name, "watch", watch.String(), "index", index)
+		return nil
+	}
+	// the REST mapper may not know the resource of the CRD just established
+	d.informer.Refresh()
+	indices, err := addConfigWatch(d.informer, watch)
+	if err != nil {
+		// retried as a whole
+		d.stop(configWatch{watch, indices})
+		return fmt.Errorf("failed to watch custom resources of %s: %v", name, err)
+	}
+	d.watches[name] = configWatch{watch, indices}
+	logger.Info("added watch of custom resources", "crd", name, "watch", watch.String(), "indices", indices)
+	return nil
+}
+
+func (d *crdDiscovery) stop(watch configWatch) {
+	for _, index := range watch.indices {
+		if err := d.informer.StopWatch(index); err != nil {
+			logger.Error("failed to stop watch", err, "watch", watch.String(), "index", index)
+		}
+	}
+}
+
+// watched returns the index of a watch of the kind not added by the discovery, or -1
+func (d *crdDiscovery) watched(watch watchConfig) int {
+	added := map[int]bool{}
+	for _, running := range d.watches {
+		for _, index := range running.indices {
+			added[index] = true
+		}
+	}
+	for _, info := range d.informer.WatchStatus() {
+		if !added[info.Index] && info.APIVersion == watch.APIVersion && info.Kind == watch.Kind {
+			return info.Index
+		}
+	}
+	return -1
+}
+
+// crdEstablished returns true if the Established condition of the CRD is true, the resources are not served before
+func crdEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		if condition, ok := c.(map[string]interface{}); ok && condition["type"] == "Established" {
+			return condition["status"] == "True"
+		}
+	}
+	return false
+}
+
+// crdVersion returns the storage version of the CRD if served, or the first version served
+func crdVersion(crd *unstructured.Unstructured) string {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	served := ""
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok || version["served"] != true {
+			continue
+		}
+		name, _ := version["name"].(string)
+		if version["storage"] == true {
+			return name
+		}
+		if served == "" {
+			served = name
+		}
+	}
+	if served == "" && len(versions) == 0 {
+		// apiextensions.k8s.io/v1beta1 with the single version
+		served, _, _ = unstructured.NestedString(crd.Object, "spec", "version")
+	}
+	return served
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+//DropInfo type is the retries of the event dropped
+type DropInfo struct {
+	Retries int
+	// FirstSeen is the time the event was first enqueued
+	FirstSeen time.Time
+	// Elapsed is the wall-clock time since FirstSeen until dropped
+	Elapsed time.Duration
+}
+
+type droppedEvent struct {
+	Event     EventType                  `json:"event"`
+	Object    *unstructured.Unstructured `json:"object,omitempty"`
+	Error     string                     `json:"error"`
+	Retries   int                        `json:"retries"`
+	FirstSeen time.Time                  `json:"firstSeen"`
+	Elapsed   string                     `json:"elapsed"`
+}
+
+var droppedFileLock sync.Mutex
+
+// appendDroppedEvent appends the dropped event to --dropped-file as a json line
+func appendDroppedEvent(ctx context.Context, event EventType, obj *unstructured.Unstructured, err error, info DropInfo) {
+	line, e := json.Marshal(&droppedEvent{Event: event, Object: obj, Error: err.Error(), Retries: info.Retries, FirstSeen: info.FirstSeen, Elapsed: info.Elapsed.String()})
+	if e != nil {
+		logger.Error("failed to marshal dropped event", e, "event", event)
+		return
+	}
+	droppedFileLock.Lock()
+	defer droppedFileLock.Unlock()
+	file, e := os.OpenFile(droppedFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if e != nil {
+		logger.Error("failed to open dropped file", e, "file", droppedFile)
+		return
+	}
+	defer file.Close()
+	if _, e := file.Write(append(line, '\n')); e != nil {
+		logger.Error("failed to write dropped file", e, "file", droppedFile)
+	}
+}
+
+// firstSeenMap is the time the events are first enqueued, removed once the events are done
+type firstSeenMap struct {
+	lock   sync.Mutex
+	events map[eventKey]time.Time
+}
+
+func newFirstSeenMap() *firstSeenMap {
+	return &firstSeenMap{events: map[eventKey]time.Time{}}
+}
+
+// seen returns the time the event is first seen, now if not seen before
+func (m *firstSeenMap) seen(key eventKey) time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	t, ok := m.events[key]
+	if !ok {
+		t = time.Now()
+		m.events[key] = t
+	}
+	return t
+}
+
+func (m *firstSeenMap) remove(key eventKey) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	delete(m.events, key)
+}
+
+// runExpire removes the events seen longer than ttl every ttl/2 until ctx is done, alongside the tombstones
+func (m *firstSeenMap) runExpire(ctx context.Context, ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.lock.Lock()
+			for key, t := range m.events {
+				if time.Since(t) > ttl {
+					delete(m.events, key)
+				}
+			}
+			m.lock.Unlock()
+		}
+	}
+}
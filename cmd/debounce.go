@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// debouncer holds the events of an object until it is not touched for the window
+type debouncer struct {
+	window     time.Duration
+	queue      workqueue.DelayingInterface
+	lock       sync.Mutex
+	pending    map[objectKey]*debouncedEvent
+	generation uint64
+}
+
+type debouncedEvent struct {
+	first      EventType
+	last       eventKey
+	generation uint64
+}
+
+type debounceItem struct {
+	objectKey
+	generation uint64
+}
+
+func newDebouncer(window time.Duration) *debouncer {
+	return &debouncer{
+		window:  window,
+		queue:   workqueue.NewDelayingQueue(),
+		pending: map[objectKey]*debouncedEvent{},
+	}
+}
+
+func (d *debouncer) add(key eventKey) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.generation++
+	pending, ok := d.pending[key.objectKey]
+	if !ok {
+		pending = &debouncedEvent{first: key.event}
+		d.pending[key.objectKey] = pending
+	}
+	pending.last, pending.generation = key, d.generation
+	// items of previous generations are skipped when due, so the window restarts
+	d.queue.AddAfter(debounceItem{key.objectKey, d.generation}, d.window)
+}
+
+// next blocks until an object is due, returns false once shut down
+func (d *debouncer) next() (eventKey, bool) {
+	for {
+		item, quit := d.queue.Get()
+		if quit {
+			return eventKey{}, false
+		}
+		d.queue.Done(item)
+		if key, ok := d.take(item.(debounceItem)); ok {
+			return key, true
+		}
+	}
+}
+
+func (d *debouncer) take(item debounceItem) (eventKey, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	pending, ok := d.pending[item.objectKey]
+	if !ok || pending.generation != item.generation {
+		return eventKey{}, false
+	}
+	delete(d.pending, item.objectKey)
+	key := pending.last
+	key.event = mergeEvents(pending.first, pending.last.event)
+	return key, true
+}
+
+// mergeEvents returns the event reflecting the final state of the object touched by events from first to last
+func mergeEvents(first EventType, last EventType) EventType {
+	switch {
+	case last == EventDelete:
+		return EventDelete
+	case first == EventAdd:
+		return EventAdd
+	case first == EventResync && last == EventResync:
+		return EventResync
+	default:
+		return EventUpdate
+	}
+}
+
+func (d *debouncer) shutDown() {
+	d.queue.ShutDown()
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// maxOwnerDepth limits the ownerReferences walked up by IsDescendantOf, eg. Pod -> ReplicaSet -> Deployment is 2
+const maxOwnerDepth = 8
+
+func (i *informer) IsDescendantOf(obj *unstructured.Unstructured, owner schema.GroupVersionKind, name string) (bool, error) {
+	return i.isDescendantOf(obj, owner, name, maxOwnerDepth)
+}
+
+func (i *informer) isDescendantOf(obj *unstructured.Unstructured, owner schema.GroupVersionKind, name string, depth int) (bool, error) {
+	if depth <= 0 {
+		return false, nil
+	}
+	for _, ref := range obj.GetOwnerReferences() {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			continue
+		}
+		if gv.Group == owner.Group && ref.Kind == owner.Kind && ref.Name == name {
+			return true, nil
+		}
+		parent, err := i.getOwner(obj.GetNamespace(), ref)
+		if err != nil {
+			return false, fmt.Errorf("failed to get owner %s %s: %v", ref.Kind, ref.Name, err)
+		}
+		if parent == nil {
+			// deleted or replaced, eg. orphaned
+			continue
+		}
+		if ok, err := i.isDescendantOf(parent, owner, name, depth-1); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// getOwner returns the owner from the caches of the watches of the kind, or from apiserver if not cached (eg. not selected),
+// nil if not found or the uid changed
+func (i *informer) getOwner(namespace string, ref metav1.OwnerReference) (*unstructured.Unstructured, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	for _, watch := range i.watches.list() {
+		watchGV, err := schema.ParseGroupVersion(watch.apiVersion)
+		if err != nil || watchGV.Group != gv.Group || watch.kind != ref.Kind {
+			continue
+		}
+		for _, key := range []string{namespace + "/" + ref.Name, ref.Name} {
+			if cached, exists, err := watch.watcher.GetIndexer().GetByKey(key); err == nil && exists {
+				if owner, ok := cached.(*unstructured.Unstructured); ok && owner.GetUID() == ref.UID {
+					return owner, nil
+				}
+			}
+		}
+	}
+	resourceClient, _, _, err := i.getResourceClient(ref.APIVersion, ref.Kind, namespace)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := resourceClient.Get(ref.Name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if owner.GetUID() != ref.UID {
+		return nil, nil
+	}
+	return owner, nil
+}
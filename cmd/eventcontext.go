@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+// eventSource is the source of the event passed to Handler, stored in ctx as one value
+type eventSource struct {
+	watch        string
+	gvk          schema.GroupVersionKind
+	key          string
+	client       dynamic.Interface
+	fieldManager string
+}
+
+type eventSourceContextKey struct{}
+
+func withEventSource(ctx context.Context, watch *informerWatch, obj *unstructured.Unstructured) context.Context {
+	key := obj.GetName()
+	if namespace := obj.GetNamespace(); namespace != "" {
+		key = namespace + "/" + key
+	}
+	return context.WithValue(ctx, eventSourceContextKey{}, &eventSource{
+		watch:        watch.name,
+		gvk:          obj.GroupVersionKind(),
+		key:          key,
+		client:       watch.informer.dynamicClient,
+		fieldManager: watch.informer.FieldManager,
+	})
+}
+
+func eventSourceFromContext(ctx context.Context) *eventSource {
+	source, _ := ctx.Value(eventSourceContextKey{}).(*eventSource)
+	if source == nil {
+		return &eventSource{}
+	}
+	return source
+}
+
+//WatchNameFromContext func returns the name of the watch of the event passed to Handler (or OnAdd, OnUpdate, OnDelete and WatchOpts.Handler),
+//empty otherwise (eg. BatchHandler)
+func WatchNameFromContext(ctx context.Context) string {
+	return eventSourceFromContext(ctx).watch
+}
+
+//GroupVersionKindFromContext func returns the GroupVersionKind of the object of the event passed to Handler
+func GroupVersionKindFromContext(ctx context.Context) schema.GroupVersionKind {
+	return eventSourceFromContext(ctx).gvk
+}
+
+//ObjectKeyFromContext func returns the key (`<namespace>/<name>`, or `<name>` if cluster-scoped) of the object of the event passed to Handler
+func ObjectKeyFromContext(ctx context.Context) string {
+	return eventSourceFromContext(ctx).key
+}
+
+//DynamicClientFromContext func returns the dynamic client of the informer (of the cluster with MultiInformer) passed to Handler,
+//eg. for handlers writing back to apiserver with FieldManagerFromContext, nil otherwise
+func DynamicClientFromContext(ctx context.Context) dynamic.Interface {
+	return eventSourceFromContext(ctx).client
+}
+
+//FieldManagerFromContext func returns InformerOpts.FieldManager passed to Handler, eg. as the field manager of server-side apply
+func FieldManagerFromContext(ctx context.Context) string {
+	return eventSourceFromContext(ctx).fieldManager
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// fifoQueue is the queue of the events of a worker with InformerOpts.NoCoalesce,
+// the events of the same object are queued to the same worker in order and handled one by one
+type fifoQueue struct {
+	lock   sync.Mutex
+	cond   *sync.Cond
+	events []*queuedEvent
+	max    int
+	policy QueuePolicy
+	closed bool
+}
+
+func newFIFOQueues(workers int, maxLength int, policy QueuePolicy) []*fifoQueue {
+	max := 0
+	if maxLength > 0 {
+		// the limit is split by the workers
+		max = (maxLength + workers - 1) / workers
+	}
+	queues := make([]*fifoQueue, workers)
+	for n := range queues {
+		queues[n] = &fifoQueue{max: max, policy: policy}
+		queues[n].cond = sync.NewCond(&queues[n].lock)
+	}
+	return queues
+}
+
+// push returns the event dropped if the queue is full (the event itself with QueueDropNewest), or blocks with QueueBlock
+func (q *fifoQueue) push(e *queuedEvent) (*queuedEvent, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for q.max > 0 && q.policy == QueueBlock && len(q.events) >= q.max && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		// dropped on shutdown as the events queued
+		return nil, false
+	}
+	var dropped *queuedEvent
+	if q.max > 0 && len(q.events) >= q.max {
+		if q.policy == QueueDropNewest {
+			return e, true
+		}
+		dropped, q.events = q.events[0], q.events[1:]
+	}
+	q.events = append(q.events, e)
+	q.cond.Broadcast()
+	return dropped, dropped != nil
+}
+
+// pop blocks until an event is queued, returns false once shut down and drained
+func (q *fifoQueue) pop() (*queuedEvent, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	for len(q.events) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.events) == 0 {
+		return nil, false
+	}
+	e := q.events[0]
+	q.events[0] = nil
+	q.events = q.events[1:]
+	q.cond.Broadcast()
+	return e, true
+}
+
+func (q *fifoQueue) len() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.events)
+}
+
+// shutDown releases the watches and workers blocked, the events queued are dropped unless drain
+func (q *fifoQueue) shutDown(drain bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	q.closed = true
+	if !drain {
+		q.events = nil
+	}
+	q.cond.Broadcast()
+}
+
+// pushed queues the event with copies of the states of the object at the event if NoCoalesce, returns false otherwise
+func (w *informerWatch) pushed(event EventType, key string, obj *unstructured.Unstructured, old *unstructured.Unstructured, synced bool) bool {
+	i := w.informer
+	if i.fifo == nil {
+		return false
+	}
+	e := &queuedEvent{
+		eventKey:  eventKey{objectKey{w.index, key}, event, synced},
+		watch:     w,
+		event:     event,
+		obj:       obj.DeepCopy(),
+		exists:    event != EventDelete,
+		firstSeen: time.Now(),
+	}
+	if old != nil {
+		e.old = old.DeepCopy()
+	}
+	queue := i.fifo[keyShard(e.objectKey, len(i.fifo))]
+	if dropped, full := queue.push(e); full {
+		i.metrics.queueDropped.WithLabelValues(string(dropped.event), dropped.watch.name).Inc()
+		i.Logger.Info("queue full, dropped event", "event", dropped.event, "key", dropped.key, "policy", i.QueuePolicy)
+	}
+	return true
+}
+
+// processNextEvent handles the next event of the queue of the worker with NoCoalesce, the failed event is retried
+// by the worker before the next events (so that the events of the same object are handled in order)
+func (i *informer) processNextEvent(ctx context.Context, queue *fifoQueue) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	e, ok := queue.pop()
+	if !ok {
+		return false
+	}
+	watch, ok := i.watches.get(e.watchIndex)
+	if !ok || watch.isExcluded(e.key) {
+		return true
+	}
+	// the watch replaced by UpdateSelector
+	e.watch = watch
+	if i.MaxObjectBytes > 0 {
+		if size := objectSize(e.obj.Object); size > i.MaxObjectBytes {
+			i.Logger.Info("skipped oversized object", "event", e.event, "key", e.key, "watch", e.watch.name, "size", size, "maxObjectBytes", i.MaxObjectBytes)
+			i.metrics.oversized.WithLabelValues(string(e.event), e.watch.name).Inc()
+			return true
+		}
+	}
+	e.matched = e.watch.match(e.event, e.obj, e.old)
+	for {
+		var err error
+		if e.matched {
+			if err := i.throttle(ctx, e.watch); err != nil {
+				return false
+			}
+			if i.DryRun {
+				i.dryRun(e)
+			} else {
+				err = i.handle(ctx, e.watch, e.event, e.obj, e.old, e.numRetries, e.synced)
+			}
+		}
+		if err == nil || !i.retryable(e, err) {
+			i.complete(ctx, e, err)
+			return true
+		}
+		i.Logger.Error("error processing", err, "event", e.event, "key", e.key, "watch", e.watch.name, "retries", e.numRetries, "maxRetries", i.getMaxRetries())
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(i.retryDelay(e, err)):
+		}
+		e.numRetries++
+	}
+}
+
+func (i *informer) shutDownFIFO(drain bool) {
+	for _, queue := range i.fifo {
+		queue.shutDown(drain)
+	}
+}
+
+// queueLen returns the number of events waiting in the queue, or the queues of the workers with NoCoalesce
+func (i *informer) queueLen() int {
+	length := i.queue.Len()
+	for _, queue := range i.fifo {
+		length += queue.len()
+	}
+	return length
+}
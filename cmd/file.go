@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type fileEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	*webhookEvent
+}
+
+func handleFileEvent(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+	if !handlerEvents[event] {
+		return nil
+	}
+	data, err := json.Marshal(&fileEvent{
+		Timestamp: time.Now(),
+		webhookEvent: &webhookEvent{
+			Cluster:         ClusterFromContext(ctx),
+			Event:           event,
+			Object:          obj,
+			OldObject:       old,
+			Retries:         numRetries,
+			Synced:          synced,
+			LastAppliedDiff: LastAppliedDiffFromContext(ctx),
+		},
+	})
+	if err != nil {
+		return PermanentError(fmt.Errorf("failed to marshal event: %v", err))
+	}
+	return fileClient.Append(data)
+}
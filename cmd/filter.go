@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+//Filter interface
+type Filter interface {
+	Match(event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured) (bool, error)
+}
+
+// compileFilter is only available when built with `-tags cel`
+var compileFilter func(expr string) (Filter, error)
+
+//CompileFilter func
+func CompileFilter(expr string) (Filter, error) {
+	if compileFilter == nil {
+		return nil, fmt.Errorf("filter expressions not supported, rebuild with -tags cel")
+	}
+	return compileFilter(expr)
+}
@@ -0,0 +1,61 @@
+// +build cel
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func init() {
+	compileFilter = compileCELFilter
+}
+
+type celFilter struct {
+	program cel.Program
+}
+
+func compileCELFilter(expr string) (Filter, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("event", cel.StringType),
+		cel.Variable("object", cel.DynType),
+		cel.Variable("oldObject", cel.DynType),
+	)
+	if err != nil {
+		return nil, err
+	}
+	ast, issues := env.Compile(expr)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("failed to compile filter %q: %v", expr, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("filter %q must return bool, got %v", expr, ast.OutputType())
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile filter %q: %v", expr, err)
+	}
+	return &celFilter{program}, nil
+}
+
+func (f *celFilter) Match(event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured) (bool, error) {
+	var oldObject map[string]interface{}
+	if old != nil {
+		oldObject = old.Object
+	}
+	out, _, err := f.program.Eval(map[string]interface{}{
+		"event":     string(event),
+		"object":    obj.Object,
+		"oldObject": oldObject,
+	})
+	if err != nil {
+		return false, err
+	}
+	match, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("filter returned %v, not bool", out.Value())
+	}
+	return match, nil
+}
@@ -6,31 +6,88 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
+	"sync/atomic"
 
+	"github.com/xiaopal/kube-informer/pkg/logging"
 	"github.com/xiaopal/kube-informer/pkg/subreaper"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
-func handleEvent(ctx context.Context, event EventType, obj *unstructured.Unstructured, numRetries int) error {
+// execMaxRetries is INFORMER_MAX_RETRIES of the exec handler, updated by the config reloader
+var execMaxRetries int32
+
+func handleEvent(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
 	if !handlerEvents[event] {
 		return nil
 	}
-	handler := exec.CommandContext(ctx, handlerCommand[0], handlerCommand[1:]...)
-	if err := setupHandler(handler, event, obj, numRetries, handlerMaxRetries); err != nil {
+	args, err := renderArgs(obj)
+	if err != nil {
+		return PermanentError(fmt.Errorf("failed to render handler args: %v", err))
+	}
+	runCtx := ctx
+	if handlerTimeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, handlerTimeout)
+		defer cancel()
+	}
+	handler := exec.CommandContext(runCtx, handlerCommand[0], append(handlerCommand[1:len(handlerCommand):len(handlerCommand)], args...)...)
+	if err := setupHandler(handler, event, obj, old, numRetries, int(atomic.LoadInt32(&execMaxRetries)), synced); err != nil {
 		return fmt.Errorf("failed to setup handler: %v", err)
 	}
+	if cluster := ClusterFromContext(ctx); cluster != "" {
+		handler.Env = append(handler.Env, fmt.Sprintf("INFORMER_CLUSTER=%s", cluster))
+	}
+	if fieldManager := FieldManagerFromContext(ctx); fieldManager != "" {
+		handler.Env = append(handler.Env, fmt.Sprintf("INFORMER_FIELD_MANAGER=%s", fieldManager))
+	}
+	if handlerPassStdinEvent {
+		payload, err := json.Marshal(&webhookEvent{
+			Cluster:         ClusterFromContext(ctx),
+			Event:           event,
+			Object:          obj,
+			OldObject:       old,
+			Retries:         numRetries,
+			Synced:          synced,
+			LastAppliedDiff: LastAppliedDiffFromContext(ctx),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %v", err)
+		}
+		handler.Stdin = bytes.NewReader(payload)
+	}
+	if diff := LastAppliedDiffFromContext(ctx); diff != nil {
+		jsonDiff, err := json.Marshal(diff)
+		if err != nil {
+			return fmt.Errorf("failed to marshal last-applied-configuration diff: %v", err)
+		}
+		handler.Env = append(handler.Env, fmt.Sprintf("INFORMER_LAST_APPLIED_CHANGED=%t", !diff.Empty()), fmt.Sprintf("INFORMER_LAST_APPLIED_DIFF=%s", string(jsonDiff)))
+	}
 	subreaper.Pause()
 	defer subreaper.Resume()
 	if err := handler.Run(); err != nil {
+		if ctx.Err() == nil && runCtx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("handler timed out after %v", handlerTimeout)
+		}
 		return fmt.Errorf("failed to execute handler: %v", err)
 	}
 	return nil
 }
 
+func renderArgs(obj *unstructured.Unstructured) ([]string, error) {
+	args := make([]string, 0, len(handlerArgTemplates))
+	for _, t := range handlerArgTemplates {
+		buf := &bytes.Buffer{}
+		if err := t.Execute(buf, obj.Object); err != nil {
+			return nil, err
+		}
+		args = append(args, buf.String())
+	}
+	return args, nil
+}
+
 func formatTimestamp(time *metav1.Time) string {
 	if time == nil {
 		return ""
@@ -39,13 +96,17 @@ func formatTimestamp(time *metav1.Time) string {
 	return ret
 }
 
-func setupHandler(handler *exec.Cmd, event EventType, obj *unstructured.Unstructured, numRetries int, maxRetries int) error {
-	logger := log.New(os.Stderr, fmt.Sprintf("[%s] ", handlerName), log.Flags())
+func setupHandler(handler *exec.Cmd, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, maxRetries int, synced bool) error {
+	logger, err := logging.New(logFormat, os.Stderr, handlerName)
+	if err != nil {
+		return err
+	}
 	creationTime := obj.GetCreationTimestamp()
 	handler.Env = append(os.Environ(),
 		fmt.Sprintf("INFORMER_EVENT=%s", event),
 		fmt.Sprintf("INFORMER_RETRIES=%d", numRetries),
 		fmt.Sprintf("INFORMER_MAX_RETRIES=%d", maxRetries),
+		fmt.Sprintf("INFORMER_SYNCED=%t", synced),
 		fmt.Sprintf("INFORMER_OBJECT_NAME=%s", obj.GetName()),
 		fmt.Sprintf("INFORMER_OBJECT_NAMESPACE=%s", obj.GetNamespace()),
 		fmt.Sprintf("INFORMER_OBJECT_API_VERSION=%s", obj.GetAPIVersion()),
@@ -54,12 +115,26 @@ func setupHandler(handler *exec.Cmd, event EventType, obj *unstructured.Unstruct
 		fmt.Sprintf("INFORMER_DELETION_TIMESTAMP=%s", formatTimestamp(obj.GetDeletionTimestamp())),
 		fmt.Sprintf("INFORMER_CREATION_TIMESTAMP=%s", formatTimestamp(&creationTime)),
 	)
+	if outputTemplate != "" {
+		output, err := renderOutput(obj)
+		if err != nil {
+			return fmt.Errorf("failed to render output: %v", err)
+		}
+		handler.Env = append(handler.Env, fmt.Sprintf("INFORMER_OUTPUT=%s", output))
+	}
 	jsonObj, err := json.Marshal(obj)
 	if err != nil {
 		return fmt.Errorf("failed to marshal obj: %v", err)
 	}
 	if handlerPassEnv {
 		handler.Env = append(handler.Env, fmt.Sprintf("INFORMER_OBJECT=%s", string(jsonObj)))
+		if old != nil {
+			jsonOld, err := json.Marshal(old)
+			if err != nil {
+				return fmt.Errorf("failed to marshal old obj: %v", err)
+			}
+			handler.Env = append(handler.Env, fmt.Sprintf("INFORMER_OLD_OBJECT=%s", string(jsonOld)))
+		}
 	}
 	if handlerPassArgs {
 		handler.Args = append(handler.Args, string(event), string(jsonObj))
@@ -67,14 +142,14 @@ func setupHandler(handler *exec.Cmd, event EventType, obj *unstructured.Unstruct
 	if handlerPassStdin {
 		handler.Stdin = bytes.NewReader(jsonObj)
 	}
-	if err := pipeStderr(handler, logger); err != nil {
+	if err := pipeStderr(handler, logger, "event", event, "namespace", obj.GetNamespace(), "name", obj.GetName()); err != nil {
 		return fmt.Errorf("failed to pipe stderr: %v", err)
 	}
 	handler.Stdout = os.Stdout
 	return nil
 }
 
-func pipeStderr(cmd *exec.Cmd, logger *log.Logger) error {
+func pipeStderr(cmd *exec.Cmd, logger logging.Logger, keysAndValues ...interface{}) error {
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		return err
@@ -82,7 +157,7 @@ func pipeStderr(cmd *exec.Cmd, logger *log.Logger) error {
 	go func() {
 		o := bufio.NewScanner(stderr)
 		for o.Scan() {
-			logger.Println(o.Text())
+			logger.Info(o.Text(), keysAndValues...)
 		}
 	}()
 	return nil
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+var (
+	runningInformerLock sync.RWMutex
+	runningInformer     Informer
+)
+
+func setRunningInformer(informer Informer) {
+	runningInformerLock.Lock()
+	defer runningInformerLock.Unlock()
+	runningInformer = informer
+}
+
+func getRunningInformer() Informer {
+	runningInformerLock.RLock()
+	defer runningInformerLock.RUnlock()
+	return runningInformer
+}
+
+func statusHandler(ok func() bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ok() {
+			http.Error(w, "not ok", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+}
+
+//ReadyzHandler func responds 200 once the running informer has synced, not ready while not leading
+func ReadyzHandler() http.Handler {
+	return statusHandler(func() bool {
+		informer := getRunningInformer()
+		return informer != nil && informer.HasSynced()
+	})
+}
+
+//WatchesHandler func responds the status of the watches of the running informer as json
+func WatchesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		infos := []WatchInfo{}
+		if informer := getRunningInformer(); informer != nil {
+			infos = informer.WatchStatus()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(infos)
+	})
+}
+
+//HealthzHandler func responds 200 unless the running informer lost workers
+func HealthzHandler() http.Handler {
+	return statusHandler(func() bool {
+		informer := getRunningInformer()
+		return informer == nil || informer.Healthy()
+	})
+}
+
+//ReprocessHandler func enqueues an update event of the object of the running informer by `POST ?watch=<index>&namespace=<namespace>&name=<name>`,
+//it responds 404 if the object is not cached
+func ReprocessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		query := r.URL.Query()
+		index, err := strconv.Atoi(query.Get("watch"))
+		if err != nil || query.Get("name") == "" {
+			http.Error(w, "watch index and name required", http.StatusBadRequest)
+			return
+		}
+		informer := getRunningInformer()
+		if informer == nil {
+			http.Error(w, "not running", http.StatusServiceUnavailable)
+			return
+		}
+		found, err := informer.Reprocess(index, query.Get("namespace"), query.Get("name"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if !found {
+			http.Error(w, fmt.Sprintf("object %s/%s not found", query.Get("namespace"), query.Get("name")), http.StatusNotFound)
+			return
+		}
+		logger.Info("reprocess requested", "watch", index, "namespace", query.Get("namespace"), "name", query.Get("name"), "remote", r.RemoteAddr)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("enqueued"))
+	})
+}
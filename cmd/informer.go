@@ -3,11 +3,20 @@ package main
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xiaopal/kube-informer/pkg/logging"
+	"golang.org/x/time/rate"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -18,17 +27,219 @@ import (
 	"k8s.io/client-go/restmapper"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/util/wait"
-	dynamic "k8s.io/client-go/deprecated-dynamic"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 )
 
 //InformerOpts type
 type InformerOpts struct {
-	Handler     func(ctx context.Context, event EventType, obj *unstructured.Unstructured, numRetries int) error
-	MaxRetries  int
-	RateLimiter workqueue.RateLimiter
+	// Handler is optional if events are received from Informer.Events().
+	// Handler is called with synced=false for the add events of the initial list of the watch,
+	// and the other events received before the add events of all objects of the initial list were delivered.
+	// For update events old is the object before the first update enqueued since the key was last handled,
+	// as several updates of the same object may be coalesced into one event.
+	Handler func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error
+	// EventHandler is called with the events instead of Handler, OnAdd, OnUpdate and OnDelete if set,
+	// so that fields added to Event later are passed without changing the signature. WatchOpts.Handler is still called instead.
+	EventHandler func(ctx context.Context, event Event) error
+	// OnAdd, OnUpdate and OnDelete are called instead of Handler if any of them is set,
+	// the events are skipped if the handler of the event type is nil. OnUpdate is called with nil old on resync and reconcile events.
+	OnAdd      func(ctx context.Context, obj *unstructured.Unstructured, numRetries int, synced bool) error
+	OnUpdate   func(ctx context.Context, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error
+	OnDelete   func(ctx context.Context, obj *unstructured.Unstructured, numRetries int, synced bool) error
+	MaxRetries int
+	// RetryPolicy defaults to AtLeastOnce, with AtMostOnce the failed events are dropped (see OnDropped) instead of retried
+	RetryPolicy RetryPolicy
+	// RateLimiter delays the retries of failed events, if nil an exponential backoff is built
+	// from RetryBaseDelay (defaults to 5ms) to RetryMaxDelay (defaults to 1000s), with up to RetryJitter (eg. 0.1) of the delay added randomly
+	RateLimiter    workqueue.RateLimiter
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	RetryJitter    float64
+	// BatchHandler is called instead of Handler, OnAdd, OnUpdate and OnDelete if set, with the events dequeued by a worker
+	// until BatchSize (defaults to 100) events or BatchTimeout (defaults to 1s) elapsed since the first event.
+	// All events of the batch are retried if it returns an error, the objects of delete events are the last known states.
+	BatchHandler func(ctx context.Context, events []Event) error
+	BatchSize    int
+	BatchTimeout time.Duration
+	// OnDropped is called with the last error when an event is dropped as the retries exhausted or the error is permanent,
+	// obj is nil if the object failed to be got from the cache. info is the retries of the event, eg. to debug why it kept failing.
+	OnDropped func(ctx context.Context, event EventType, obj *unstructured.Unstructured, err error, info DropInfo)
+	// MaxQueueLength limits the events waiting in the queue by QueuePolicy (defaults to QueueBlock), 0 for unlimited.
+	// The events dropped by QueueDropOldest and QueueDropNewest are never handled, only for best-effort use cases as it breaks at-least-once.
+	// With QueueBlock the events are buffered by the watches instead, which still grows unbounded but keeps the events.
+	MaxQueueLength int
+	QueuePolicy    QueuePolicy
+	// NoCoalesce handles every event in order with the states of the object at the event, instead of the latest state cached
+	// with the repeated events of the same object coalesced while queued, eg. for audit. The events are queued to the workers
+	// by the objects, each holding copies of the object (and the old object of updates), so the memory grows with the events queued
+	// rather than the objects (see MaxQueueLength). A failed event is retried by the worker before its next events.
+	// DebounceWindow, ReconcileInterval, BatchHandler and WatchOpts.MaxConcurrent are not applied.
+	NoCoalesce bool
+	// Workers is the number of goroutines processing the queue, defaults to 1.
+	// Handler may be called concurrently for different objects, the events of the same object are handled one at a time
+	// in the order dequeued (the retries of a failed event and the events requeued by WatchOpts.MaxConcurrent are handled
+	// after the events of the object queued meanwhile). It may be changed while running by SetWorkers.
+	Workers int
+	// Metrics registers the informer metrics while running if not nil
+	Metrics prometheus.Registerer
+	// QueueName names the queue for the metrics provider of workqueue (see RegisterWorkqueueMetrics),
+	// the queue metrics (depth, adds, latency, retries etc.) are not collected if empty.
+	QueueName string
+	// DrainOnShutdown keeps handling the queued events after ctx is cancelled, until the queue is empty
+	// or DrainTimeout (0 for no timeout) elapses. Retries of failed events are not queued while draining.
+	DrainOnShutdown bool
+	DrainTimeout    time.Duration
+	// DebounceWindow holds the events of an object until it is not touched for the window (0 to disable),
+	// the events are handled once with the latest state of the object.
+	DebounceWindow time.Duration
+	// ReconcileInterval handles the objects level-triggered instead of per event (0 to disable, DebounceWindow is ignored if set):
+	// the objects added, updated or resynced are marked dirty, and each dirty object is handled at most once per interval
+	// as EventReconcile (with nil old) reading the latest state from the cache. Delete events are still handled immediately.
+	ReconcileInterval time.Duration
+	// EventRate limits the events handled per second by all watches (0 for unlimited) with burst of EventBurst,
+	// the events waiting for the limit are not counted as retries.
+	EventRate  float64
+	EventBurst int
+	// Transform modifies the listed and watched objects before they are cached, eg. DropManagedFields to save memory
+	Transform func(obj *unstructured.Unstructured)
+	// Filter is an expression (CEL) over `event`, `object` and `oldObject`, events are skipped unless it returns true
+	Filter string
+	// CheckpointFile records the resourceVersions of handled objects every CheckpointInterval (default 10s) and on exit,
+	// the initial add events of unchanged objects are skipped after restarts,
+	// and delete events are replayed for the recorded objects not found (with only apiVersion, kind, namespace and name)
+	CheckpointFile     string
+	CheckpointInterval time.Duration
+	// OnWatchError is called with the name of the watch and the errors of the list and watch requests, the watch is retried by the informer.
+	// The category tells the errors needing intervention (eg. WatchErrorForbidden) from those recovered by the informer (eg. WatchErrorGone).
+	OnWatchError func(watch string, category WatchErrorCategory, err error)
+	// OnSynced is called once with the name of each watch when the initial list is synced (and replaced by Informer.UpdateSelector),
+	// before the workers handle the events of the watches added before Run. Watches not synced within SyncTimeout with PartialSync are not called.
+	OnSynced func(watch string)
+	// SkipOlderThan skips the add events enqueued before the initial list of the watch was synced
+	// if the objects were created longer than the duration ago (0 to disable), the later events are not affected
+	SkipOlderThan time.Duration
+	// SyncTimeout fails Run (or Watch while running) unless the caches of all watches are synced within the duration, 0 for no timeout
+	SyncTimeout time.Duration
+	// PartialSync starts handling the events of the watches synced within SyncTimeout instead of failing,
+	// the watches not synced (eg. namespaces forbidden by RBAC) keep retrying, see WatchStatus
+	PartialSync bool
+	// AccessCheck reviews the permissions to list and watch of each watch when added by SelfSubjectAccessReview,
+	// AccessCheckFail fails the watch with the permissions missing (eg. to fail fast at startup), AccessCheckWarn logs them.
+	// Defaults to AccessCheckSkip.
+	AccessCheck AccessCheck
+	// TombstoneTTL evicts the last known states of deleted objects kept longer than the duration (0 to disable),
+	// delete events still queued after the TTL are skipped as no last known state found
+	TombstoneTTL time.Duration
+	// IgnoreDeletes skips the delete events when received (including those replayed by CheckpointFile and UpdateSelector),
+	// the last known states are not kept and the handlers are never called with delete events.
+	// The add and update events queued before the objects deleted are still skipped.
+	IgnoreDeletes bool
+	// DryRun logs the events instead of calling the handlers (or emitting to Informer.Events()),
+	// the checkpoint is not updated. The events are counted by the metric dry_run_events_total.
+	DryRun bool
+	// Protobuf lists and watches the built-in kinds with protobuf instead of json to reduce the CPU and memory of decoding,
+	// the objects are still converted to unstructured. Other kinds (eg. CRDs) use json. Only with NewInformer.
+	Protobuf bool
+	// HandlerReadOnly passes the cached objects to the handlers (and Informer.Events()) without DeepCopy to reduce allocations,
+	// the handlers must not modify the objects (nor keep them to modify later) as the cache is shared by the watch.
+	HandlerReadOnly bool
+	// FieldManager is passed to the handlers in ctx with the dynamic client (see FieldManagerFromContext and DynamicClientFromContext),
+	// so that the handlers writing back to apiserver use a consistent field manager, eg. to avoid conflicts of server-side apply.
+	// The informer itself never writes.
+	FieldManager string
+	// HandlerTimeout cancels the ctx of each call of the handlers (Handler, OnAdd, OnUpdate, OnDelete, EventHandler, BatchHandler
+	// and WatchOpts.Handler) after the duration (0 for no timeout), the events of the calls timed out with errors are retried.
+	// Handlers ignoring ctx still block the workers.
+	HandlerTimeout time.Duration
+	// DiscoveryRefreshInterval resets the cached discovery and the REST mapper periodically while running (0 to disable),
+	// so that resources installed later (eg. CRDs) are watched without calling Informer.Refresh
+	DiscoveryRefreshInterval time.Duration
+	// MaxObjectBytes skips the events of objects larger than the size (estimated from the fields, 0 for unlimited) before copied,
+	// the events are logged and counted by the metric oversized_objects_total
+	MaxObjectBytes int
+	// Tracer starts a span around each event (or batch) handled if not nil, eg. NewOTelTracer built with `-tags otel`,
+	// the trace context is passed to the handlers in ctx and propagated by the webhook and kafka handlers, see TraceHeaders
+	Tracer Tracer
+	// Logger logs the informer (eg. with a distinct name per instance), defaults to the logger of the command
+	Logger logging.Logger
+}
+
+//WatchOpts type
+type WatchOpts struct {
+	// Name watches the single object of the name (in the namespace if namespaced) with field selector metadata.name
+	Name          string
+	LabelSelector string
+	FieldSelector string
+	Resync        time.Duration
+	// WatchTimeout closes the watch requests by apiserver after the timeout (0 for the random 5-10m of client-go) and relists the objects,
+	// eg. if watches go stale behind proxies. Unlike Resync replaying the cache, the objects changed are listed from apiserver.
+	WatchTimeout time.Duration
+	// ExcludeNamespaces skips events of objects in these namespaces, cluster-scoped objects are not affected
+	ExcludeNamespaces []string
+	// Owner skips events of objects not owned by the owner if not nil
+	Owner *OwnerFilter
+	// Transition skips events unless the objects transition into the value at the path if not nil
+	Transition *TransitionFilter
+	// GenerationChangesOnly skips update events unless metadata.generation changed (eg. status only updates),
+	// objects without generation are not affected
+	GenerationChangesOnly bool
+	// StatusChangesOnly skips update events unless the status subtree changed (eg. spec or metadata only updates),
+	// as the status subresource is not watchable on its own
+	StatusChangesOnly bool
+	// DiffLastApplied passes the diff of the kubectl last-applied-configuration annotation on update events,
+	// see LastAppliedDiffFromContext and Event.LastAppliedDiff
+	DiffLastApplied bool
+	// AnnotationMatch skips events of objects unless all the annotations match, empty values match any values of the keys
+	AnnotationMatch map[string]string
+	// AnnotationExclude skips events of objects matching any of the annotations, empty values match any values of the keys
+	AnnotationExclude map[string]string
+	// EventRate limits the events of the watch handled per second (0 for unlimited) with burst of EventBurst
+	EventRate  float64
+	EventBurst int
+	// MaxConcurrent limits the events of the watch handled concurrently by the workers (0 for up to InformerOpts.Workers),
+	// the events beyond are requeued so that the other watches are still handled by the idle workers. It is not applied to BatchHandler.
+	MaxConcurrent int
+	// Indexers of the cache queried by Informer.ByIndex, cache.NamespaceIndex is always added
+	Indexers cache.Indexers
+	// ListChunkSize lists the objects in chunks from etcd instead of at once from the watch cache of apiserver, 0 to disable
+	ListChunkSize int64
+	// Handler is called for the events of the watch instead of Handler, OnAdd, OnUpdate and OnDelete of InformerOpts if not nil,
+	// eg. to handle pods and configmaps by separate handlers without switching on the kind, the watches without Handler fall back to InformerOpts.
+	// It is called for all event types (filter by the event if needed), BatchHandler is still called instead if set. See WatchTyped.
+	Handler func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error
+}
+
+//Event type
+type Event struct {
+	Type      EventType
+	Object    *unstructured.Unstructured
+	OldObject *unstructured.Unstructured
+	// Cluster is set by MultiInformer
+	Cluster string
+	// Watch is the name of the watch of the event
+	Watch string
+	// LastAppliedDiff is set on update events if WatchOpts.DiffLastApplied
+	LastAppliedDiff *ConfigDiff
+	Retries         int
+	Synced          bool
+}
+
+//WatchInfo type is the status of a watch
+type WatchInfo struct {
+	Index         int    `json:"index"`
+	Cluster       string `json:"cluster,omitempty"`
+	Name          string `json:"name"`
+	APIVersion    string `json:"apiVersion"`
+	Kind          string `json:"kind"`
+	Namespace     string `json:"namespace,omitempty"`
+	LabelSelector string `json:"labelSelector,omitempty"`
+	FieldSelector string `json:"fieldSelector,omitempty"`
+	Synced        bool   `json:"synced"`
+	// Objects is the number of objects in the cache
+	Objects int `json:"objects"`
 }
 
 //EventType type
@@ -41,25 +252,194 @@ const (
 	EventUpdate EventType = "update"
 	//EventDelete constant
 	EventDelete EventType = "delete"
+	//EventResync constant, the object is not changed but resynced periodically
+	EventResync EventType = "resync"
+	//EventReconcile constant, the object is added or changed (or resynced) since last reconciled, see InformerOpts.ReconcileInterval
+	EventReconcile EventType = "reconcile"
 )
 
+//RetryPolicy type
+type RetryPolicy string
+
+const (
+	//AtLeastOnce constant, the failed events are retried up to MaxRetries
+	AtLeastOnce RetryPolicy = "at-least-once"
+	//AtMostOnce constant, the failed events are not retried, eg. for side effects worse to duplicate than to miss
+	AtMostOnce RetryPolicy = "at-most-once"
+)
+
+// concurrentRequeueDelay delays the events requeued as the watch is at WatchOpts.MaxConcurrent
+const concurrentRequeueDelay = 100 * time.Millisecond
+
+type permanentError struct {
+	error
+}
+
+//PermanentError func wraps err returned by Handler so that the event is not retried
+func PermanentError(err error) error {
+	return &permanentError{err}
+}
+
+type retryAfterError struct {
+	error
+	delay time.Duration
+}
+
+//RetryAfterError func wraps err returned by Handler so that the event is retried after at least the delay (eg. Retry-After of 429),
+// the retry is still counted and delayed by RateLimiter if longer
+func RetryAfterError(err error, delay time.Duration) error {
+	return &retryAfterError{err, delay}
+}
+
 type informer struct {
 	InformerOpts
 	queue          workqueue.RateLimitingInterface
-	deletedObjects objectMap
-	watches        informerWatchList
-	kubeConfig     *rest.Config
-	clientPool     dynamic.ClientPool
-	restMapper     *restmapper.DeferredDiscoveryRESTMapper
+	deletedObjects *objectMap
+	updatedObjects *objectMap
+	watches        *informerWatchList
+	dynamicClient  dynamic.Interface
+	restMapper     meta.RESTMapper
+	restConfig     *rest.Config
+	metrics        *informerMetrics
+	synced         int32
+	liveWorkers    int32
+	workers        int32
+	maxRetries     int32
+	workersLock    sync.Mutex
+	pool           *workerPool
+	cluster        string
+	debouncer      *debouncer
+	reconciler     *reconciler
+	queueLimit     *queueLimit
+	fifo           []*fifoQueue
+	firstSeen      *firstSeenMap
+	keyOrder       *keyOrder
+	limiter        *rate.Limiter
+	eventsLock     sync.Mutex
+	events         chan Event
+	eventsClosed   bool
+	checkpoint     *checkpoint
+	stopLock       sync.Mutex
+	stopped        bool
+	stop           context.CancelFunc
+	syncedCh       chan struct{}
+	done           chan struct{}
+	doneOnce       sync.Once
+	runErr         error
 }
 type informerWatch struct {
-	name     string
-	informer *informer
-	index    int
-	watcher  cache.SharedIndexInformer
+	WatchOpts
+	name              string
+	apiVersion        string
+	kind              string
+	namespace         string
+	informer          *informer
+	index             int
+	watcher           cache.SharedIndexInformer
+	excludeNamespaces map[string]bool
+	filter            Filter
+	limiter           *rate.Limiter
+	concurrent        chan struct{}
+	ctx               context.Context
+	stop              context.CancelFunc
+	// opts is passed to Watch, to rebuild the watch
+	opts WatchOpts
+	// checkpointKey identifies the records of the watch in the checkpoint, unlike the name it includes the group and version
+	checkpointKey string
+	// previous is the store of the watch replaced by UpdateSelector until synced, read by the handlers
+	previous     cache.Store
+	previousLock sync.RWMutex
+	// lastResync is the time of the last object replayed by resync, see resynced
+	lastResync time.Time
+	// initialItems is the number of objects of the initial list (-1 until listed) and initialAdds the add events delivered,
+	// delivered is set once the add events of all objects of the initial list are delivered, see initialListed
+	initialItems int64
+	initialAdds  int64
+	delivered    int32
+}
+
+type informerWatchList struct {
+	sync.RWMutex
+	watches   map[int]*informerWatch
+	nextIndex int
+	ctx       context.Context
+}
+
+func newInformerWatchList() *informerWatchList {
+	return &informerWatchList{watches: map[int]*informerWatch{}}
+}
+
+// add assigns the next index to watch, returns true if the watch should be run as the watches are started
+func (l *informerWatchList) add(watch *informerWatch) bool {
+	l.Lock()
+	defer l.Unlock()
+	watch.index = l.nextIndex
+	l.nextIndex++
+	l.watches[watch.index] = watch
+	if l.ctx != nil {
+		watch.ctx, watch.stop = context.WithCancel(l.ctx)
+		return true
+	}
+	return false
 }
 
-type informerWatchList []*informerWatch
+// start returns the added watches to run, watches added later are run with ctx
+func (l *informerWatchList) start(ctx context.Context) []*informerWatch {
+	l.Lock()
+	defer l.Unlock()
+	l.ctx = ctx
+	watches := make([]*informerWatch, 0, len(l.watches))
+	for index := 0; index < l.nextIndex; index++ {
+		if watch, ok := l.watches[index]; ok {
+			watch.ctx, watch.stop = context.WithCancel(ctx)
+			watches = append(watches, watch)
+		}
+	}
+	return watches
+}
+
+// replace replaces the watch of the same index unless removed, returns true if the watch is to run
+func (l *informerWatchList) replace(old *informerWatch, watch *informerWatch) (bool, bool) {
+	l.Lock()
+	defer l.Unlock()
+	if l.watches[old.index] != old {
+		return false, false
+	}
+	watch.index = old.index
+	l.watches[watch.index] = watch
+	if l.ctx != nil {
+		watch.ctx, watch.stop = context.WithCancel(l.ctx)
+		return true, true
+	}
+	return false, true
+}
+
+func (l *informerWatchList) list() []*informerWatch {
+	l.RLock()
+	defer l.RUnlock()
+	watches := make([]*informerWatch, 0, len(l.watches))
+	for index := 0; index < l.nextIndex; index++ {
+		if watch, ok := l.watches[index]; ok {
+			watches = append(watches, watch)
+		}
+	}
+	return watches
+}
+
+func (l *informerWatchList) get(index int) (*informerWatch, bool) {
+	l.RLock()
+	defer l.RUnlock()
+	watch, ok := l.watches[index]
+	return watch, ok
+}
+
+func (l *informerWatchList) remove(index int) (*informerWatch, bool) {
+	l.Lock()
+	defer l.Unlock()
+	watch, ok := l.watches[index]
+	delete(l.watches, index)
+	return watch, ok
+}
 
 type objectKey struct {
 	watchIndex int
@@ -68,10 +448,94 @@ type objectKey struct {
 
 type eventKey struct {
 	objectKey
-	event EventType
+	event  EventType
+	synced bool
+}
+
+type objectMap struct {
+	sync.RWMutex
+	objects map[objectKey]objectEntry
+}
+
+type objectEntry struct {
+	obj   *unstructured.Unstructured
+	since time.Time
+}
+
+func newObjectMap() *objectMap {
+	return &objectMap{objects: map[objectKey]objectEntry{}}
+}
+
+func (m *objectMap) get(key objectKey) (*unstructured.Unstructured, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	entry, ok := m.objects[key]
+	return entry.obj, ok
+}
+
+func (m *objectMap) put(key objectKey, obj *unstructured.Unstructured) {
+	m.Lock()
+	defer m.Unlock()
+	m.objects[key] = objectEntry{obj, time.Now()}
+}
+
+func (m *objectMap) putIfAbsent(key objectKey, obj *unstructured.Unstructured) {
+	m.Lock()
+	defer m.Unlock()
+	if _, ok := m.objects[key]; !ok {
+		m.objects[key] = objectEntry{obj, time.Now()}
+	}
+}
+
+func (m *objectMap) take(key objectKey) *unstructured.Unstructured {
+	m.Lock()
+	defer m.Unlock()
+	entry := m.objects[key]
+	delete(m.objects, key)
+	return entry.obj
 }
 
-type objectMap map[objectKey]*unstructured.Unstructured
+func (m *objectMap) remove(key objectKey) {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.objects, key)
+}
+
+func (m *objectMap) len() int {
+	m.RLock()
+	defer m.RUnlock()
+	return len(m.objects)
+}
+
+// expire removes the objects put longer than ttl ago, returns the number of objects removed
+func (m *objectMap) expire(ttl time.Duration) int {
+	m.Lock()
+	defer m.Unlock()
+	expired := 0
+	for key, entry := range m.objects {
+		if time.Since(entry.since) > ttl {
+			delete(m.objects, key)
+			expired++
+		}
+	}
+	return expired
+}
+
+// runExpire expires the objects every ttl/2 until ctx is done
+func (m *objectMap) runExpire(ctx context.Context, ttl time.Duration, log logging.Logger) {
+	ticker := time.NewTicker(ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if expired := m.expire(ttl); expired > 0 {
+				log.Info("evicted expired tombstones", "count", expired, "ttl", ttl)
+			}
+		}
+	}
+}
 
 //NewInformer func
 func NewInformer(kubeConfig *rest.Config, opts InformerOpts) Informer {
@@ -79,180 +543,1391 @@ func NewInformer(kubeConfig *rest.Config, opts InformerOpts) Informer {
 	cachedDiscoveryClient := cached.NewMemCacheClient(kubeClient.Discovery())
 	restMapper := restmapper.NewDeferredDiscoveryRESTMapper(cachedDiscoveryClient)
 	restMapper.Reset()
-	kubeConfig.ContentConfig = dynamic.ContentConfig()
-	return &informer{
+	i := NewInformerWithClients(dynamic.NewForConfigOrDie(kubeConfig), restMapper, opts).(*informer)
+	if opts.Protobuf {
+		i.restConfig = kubeConfig
+	}
+	return i
+}
+
+//NewInformerWithClients func, eg. with fake clients in tests
+func NewInformerWithClients(dynamicClient dynamic.Interface, restMapper meta.RESTMapper, opts InformerOpts) Informer {
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+	if opts.BatchSize < 1 {
+		opts.BatchSize = 100
+	}
+	if opts.BatchTimeout <= 0 {
+		opts.BatchTimeout = time.Second
+	}
+	if opts.Logger == nil {
+		opts.Logger = logger
+	}
+	if opts.RateLimiter == nil {
+		if opts.RetryBaseDelay <= 0 {
+			opts.RetryBaseDelay = 5 * time.Millisecond
+		}
+		if opts.RetryMaxDelay < opts.RetryBaseDelay {
+			opts.RetryMaxDelay = 1000 * time.Second
+		}
+		opts.RateLimiter = NewJitterRateLimiter(NewExponentialRateLimiter(opts.RetryBaseDelay, opts.RetryMaxDelay), opts.RetryJitter)
+	}
+	i := &informer{
 		InformerOpts:   opts,
-		queue:          workqueue.NewRateLimitingQueue(opts.RateLimiter),
-		deletedObjects: objectMap{},
-		watches:        informerWatchList{},
-		kubeConfig:     kubeConfig,
-		clientPool:     dynamic.NewClientPool(kubeConfig, restMapper, dynamic.LegacyAPIPathResolverFunc),
+		queue:          workqueue.NewNamedRateLimitingQueue(opts.RateLimiter, opts.QueueName),
+		deletedObjects: newObjectMap(),
+		updatedObjects: newObjectMap(),
+		firstSeen:      newFirstSeenMap(),
+		keyOrder:       newKeyOrder(),
+		watches:        newInformerWatchList(),
+		dynamicClient:  dynamicClient,
 		restMapper:     restMapper,
+		syncedCh:       make(chan struct{}),
+		done:           make(chan struct{}),
+		workers:        int32(opts.Workers),
+		maxRetries:     int32(opts.MaxRetries),
 	}
+	if i.QueuePolicy == "" {
+		i.QueuePolicy = QueueBlock
+	}
+	if opts.NoCoalesce {
+		i.fifo = newFIFOQueues(opts.Workers, opts.MaxQueueLength, i.QueuePolicy)
+	} else if opts.MaxQueueLength > 0 {
+		i.queueLimit = newQueueLimit(opts.MaxQueueLength, i.QueuePolicy)
+	}
+	switch {
+	case opts.NoCoalesce:
+		// every event is queued by the watches
+	case opts.ReconcileInterval > 0:
+		i.reconciler = newReconciler(opts.ReconcileInterval)
+	case opts.DebounceWindow > 0:
+		i.debouncer = newDebouncer(opts.DebounceWindow)
+	}
+	i.limiter = newEventLimiter(opts.EventRate, opts.EventBurst)
+	i.metrics = newInformerMetrics(func() float64 {
+		return float64(i.queueLen())
+	}, func() float64 {
+		return float64(i.deletedObjects.len())
+	})
+	return i
 }
 
 //Informer interface
 type Informer interface {
-	Watch(apiVersion string, kind string, namespace string, selector string, resync time.Duration) error
-	Run(ctx context.Context)
+	// Watch may be called before or while running, the watches are indexed from 0 in the order they are added.
+	// apiVersion may omit the version (eg. `example.com/`, `core/` for v1) to watch the version preferred by the server.
+	// kind may be a comma separated list to add a watch for each kind, the kinds failed are returned in the error while the others are added.
+	// namespace may be a comma separated list to add a watch for each namespace (eg. to list and watch with namespaced RBAC), empty for all namespaces.
+	Watch(apiVersion string, kind string, namespace string, opts WatchOpts) error
+	// StopWatch stops the watch of index, indices of stopped watches are not reused
+	StopWatch(index int) error
+	// UpdateSelector replaces the watch of index with the label selector, waits for the new watch to sync while running.
+	// Add events are enqueued for the objects newly selected, and delete events for the objects no longer selected.
+	UpdateSelector(index int, selector string) error
+	Run(ctx context.Context) error
+	// Stop stops Run as if ctx is cancelled, it may be called more than once, Run returns immediately if stopped before
+	Stop()
+	// HasSynced returns true while running once the caches of all watches are synced
+	HasSynced() bool
+	// WaitForSync blocks until the caches of the watches added before Run are synced (and the workers are started),
+	// it returns an error if Run returns before synced or ctx is done, eg. to start serving once Run in a goroutine is ready.
+	WaitForSync(ctx context.Context) error
+	// Healthy returns true unless running with less workers than expected
+	Healthy() bool
+	// ByIndex returns the cached objects of the watch matching the index value, the objects must not be modified
+	ByIndex(index int, indexName string, indexValue string) ([]*unstructured.Unstructured, error)
+	// Get returns the cached object of the watch by namespace (empty if cluster-scoped) and name, the object must not be modified.
+	// It is safe to call from handlers, eg. to get the owner of the object cached by another watch.
+	Get(index int, namespace string, name string) (*unstructured.Unstructured, bool, error)
+	// Reprocess enqueues an update event (with nil old) of the cached object of the watch to handle it again without changes,
+	// it returns false if the object is not cached.
+	Reprocess(index int, namespace string, name string) (bool, error)
+	// IsDescendantOf returns true if the object is owned by the owner of the kind and name, directly or transitively (eg. pods of a deployment),
+	// walking up the ownerReferences by the caches of the watches of the owner kinds, or by apiserver if not cached.
+	// The version of owner is not compared. An error is returned if an owner failed to be got (unknown), eg. no permission to get the kind.
+	IsDescendantOf(obj *unstructured.Unstructured, owner schema.GroupVersionKind, name string) (bool, error)
+	// Events returns the channel receiving the events successfully handled by Handler (or all events if Handler is nil),
+	// it must be called before Run and is closed when Run returns.
+	// The workers block until the events are received, so a slow receiver slows down the queue instead of dropping events.
+	Events() <-chan Event
+	// WatchStatus returns the status of the watches in the order of indices
+	WatchStatus() []WatchInfo
+	// Refresh resets the cached discovery of the REST mapper, so that resources installed after startup (eg. CRDs) can be watched
+	Refresh()
+	// SetMaxRetries changes MaxRetries, applied to the events failed afterwards
+	SetMaxRetries(maxRetries int)
+	// SetWorkers changes Workers, applied while running (the workers removed exit after the events in progress).
+	// It is not supported with NoCoalesce, as the events are queued to the workers by the objects.
+	SetWorkers(workers int) error
 }
 
-func (i *informer) getResourceClient(apiVersion, kind, namespace string) (dynamic.ResourceInterface, string, string, error) {
+type resettableRESTMapper interface {
+	Reset()
+}
+
+func (i *informer) getResourceClient(apiVersion, kind, namespace string) (dynamic.ResourceInterface, *meta.RESTMapping, string, error) {
 	gv, err := schema.ParseGroupVersion(apiVersion)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to parse apiVersion: %v", err)
+		return nil, nil, "", fmt.Errorf("failed to parse apiVersion: %v", err)
+	}
+	if gv.Group == "core" {
+		// eg. core/v1 for v1
+		gv.Group = ""
 	}
 	gvk := schema.GroupVersionKind{
 		Group:   gv.Group,
 		Version: gv.Version,
 		Kind:    kind,
 	}
-	client, err := i.clientPool.ClientForGroupVersionKind(gvk)
-	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to get client for GroupVersionKind(%s): %v", gvk.String(), err)
+	versions := []string{}
+	if gvk.Version != "" {
+		versions = append(versions, gvk.Version)
+	}
+	mapping, err := i.restMapper.RESTMapping(gvk.GroupKind(), versions...)
+	if err != nil && i.refresh() {
+		mapping, err = i.restMapper.RESTMapping(gvk.GroupKind(), versions...)
+	}
+	if err == nil && gvk.Version == "" {
+		// eg. example.com/ for the preferred version served of the group
+		i.Logger.Info("using the preferred version", "kind", gvk.GroupKind().String(), "preferred", mapping.GroupVersionKind.Version)
+	}
+	if err != nil && meta.IsNoMatchError(err) {
+		// the version is not served (or ambiguous), fall back to the preferred version of the kind
+		if mappings, e := i.restMapper.RESTMappings(gvk.GroupKind()); e == nil && len(mappings) > 0 {
+			mapping, err = mappings[0], nil
+			i.Logger.Info("version not found, using the preferred version", "kind", gvk.GroupKind().String(), "version", gvk.Version, "preferred", mapping.GroupVersionKind.Version)
+		}
 	}
-	resource, err := apiResource(gvk, i.restMapper)
 	if err != nil {
-		return nil, "", "", fmt.Errorf("failed to get resource type: %v", err)
+		return nil, nil, "", fmt.Errorf("failed to get the resource REST mapping for GroupVersionKind(%s): %v", gvk.String(), err)
+	}
+	resourceClient := i.dynamicClient.Resource(mapping.Resource)
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return resourceClient, mapping, metav1.NamespaceAll, nil
 	}
-	if !resource.Namespaced {
-		namespace = metav1.NamespaceAll
+	return resourceClient.Namespace(namespace), mapping, namespace, nil
+}
+
+func (i *informer) Refresh() {
+	i.refresh()
+}
+
+// runRefresh refreshes the REST mapper every interval until ctx is done
+func (i *informer) runRefresh(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			i.refresh()
+		}
 	}
-	return client.Resource(resource, namespace), resource.Name, namespace, nil
 }
 
-// apiResource consults the REST mapper to translate an <apiVersion, kind, namespace> tuple to a metav1.APIResource struct.
-func apiResource(gvk schema.GroupVersionKind, restMapper *restmapper.DeferredDiscoveryRESTMapper) (*metav1.APIResource, error) {
-	mapping, err := restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+// refresh returns false if the REST mapper is not resettable
+func (i *informer) refresh() bool {
+	mapper, ok := i.restMapper.(resettableRESTMapper)
+	if ok {
+		mapper.Reset()
+	}
+	return ok
+}
+
+func (i *informer) Watch(apiVersion string, kind string, namespace string, opts WatchOpts) error {
+	_, err := i.watchIndices(apiVersion, kind, namespace, opts)
+	return err
+}
+
+// watchIndices adds the watches like Watch, it returns the indices of the watches added even if some failed
+func (i *informer) watchIndices(apiVersion string, kind string, namespace string, opts WatchOpts) ([]int, error) {
+	indices := []int{}
+	err := watchKinds(kind, func(kind string) error {
+		return watchNamespacesOnce(namespace, i.Logger, func(ns string) (string, error) {
+			index, err := i.addWatch(apiVersion, kind, ns, opts)
+			if index >= 0 {
+				indices = append(indices, index)
+			}
+			return i.clusterScopedWatch(index, ns), err
+		})
+	})
+	return indices, err
+}
+
+// clusterScopedWatch returns the name of the watch if it is watched in all namespaces instead of namespace, empty otherwise
+func (i *informer) clusterScopedWatch(index int, namespace string) string {
+	if watch, ok := i.watches.get(index); ok && watch.namespace == metav1.NamespaceAll && namespace != metav1.NamespaceAll {
+		return watch.name
+	}
+	return ""
+}
+
+// watchNamespacesOnce calls watch like watchNamespaces, the other namespaces are skipped once watch returns
+// the name of a watch of a cluster-scoped resource (watched in all namespaces once)
+func watchNamespacesOnce(namespaces string, log logging.Logger, watch func(namespace string) (string, error)) error {
+	clusterScoped := false
+	return watchNamespaces(namespaces, func(namespace string) error {
+		if clusterScoped {
+			return nil
+		}
+		name, err := watch(namespace)
+		if name != "" {
+			clusterScoped = true
+			if strings.Contains(namespaces, ",") {
+				log.Info("namespaces ignored for cluster-scoped resource", "watch", name, "namespaces", namespaces)
+			}
+		}
+		return err
+	})
+}
+
+// watchNamespaces calls watch for each namespace of the comma separated list (or once for all namespaces if empty),
+// the errors are aggregated with the namespaces
+func watchNamespaces(namespaces string, watch func(namespace string) error) error {
+	if strings.TrimSpace(namespaces) == "" {
+		return watch(metav1.NamespaceAll)
+	}
+	errs := []error{}
+	for _, namespace := range strings.Split(namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace == "" {
+			continue
+		}
+		if err := watch(namespace); err != nil {
+			errs = append(errs, fmt.Errorf("namespace %s: %v", namespace, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// watchKinds calls watch for each kind of the comma separated list, the errors are aggregated with the kinds
+func watchKinds(kinds string, watch func(kind string) error) error {
+	errs := []error{}
+	for _, kind := range strings.Split(kinds, ",") {
+		if kind = strings.TrimSpace(kind); kind == "" {
+			continue
+		}
+		if err := watch(kind); err != nil {
+			errs = append(errs, fmt.Errorf("kind %s: %v", kind, err))
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// addWatch returns the index of the watch, or -1 if the watch is not added
+func (i *informer) addWatch(apiVersion string, kind string, namespace string, opts WatchOpts) (int, error) {
+	watch, err := i.newWatch(apiVersion, kind, namespace, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get the resource REST mapping for GroupVersionKind(%s): %v", gvk.String(), err)
+		return -1, err
+	}
+	if i.watches.add(watch) {
+		i.runWatch(watch)
+		if err := i.waitForCacheSync(watch.ctx, []*informerWatch{watch}); err != nil {
+			return watch.index, err
+		}
+		if watch.watcher.HasSynced() {
+			watch.initialSynced()
+		}
 	}
-	resource := &metav1.APIResource{
-		Name:       mapping.Resource.Resource,
-		Namespaced: mapping.Scope == meta.RESTScopeNamespace,
-		Kind:       gvk.Kind,
+	return watch.index, nil
+}
+
+// initialSynced is called once the initial list of the watch is synced
+func (w *informerWatch) initialSynced() {
+	w.replayDeleted()
+	if w.informer.OnSynced != nil {
+		w.informer.OnSynced(w.name)
 	}
-	return resource, nil
 }
 
-func (i *informer) Watch(apiVersion string, kind string, namespace string, selector string, resync time.Duration) error {
-	resourceClient, resourcePluralName, namespace, err := i.getResourceClient(apiVersion, kind, namespace)
+// newWatch returns the watch not added yet
+func (i *informer) newWatch(apiVersion string, kind string, namespace string, opts WatchOpts) (*informerWatch, error) {
+	watchOpts := opts
+	resourceClient, mapping, namespace, err := i.getResourceClient(apiVersion, kind, namespace)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	resourcePluralName := mapping.Resource.Resource
+	// the version may fall back to the preferred one
+	apiVersion = mapping.GroupVersionKind.GroupVersion().String()
+	if err := i.checkAccess(mapping.Resource, namespace); err != nil {
+		return nil, err
+	}
+	if opts.Name != "" {
+		if namespace == metav1.NamespaceAll && mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			return nil, fmt.Errorf("namespace required to watch %s %q", resourcePluralName, opts.Name)
+		}
+		fieldSelector := fields.OneTermEqualSelector("metadata.name", opts.Name).String()
+		if opts.FieldSelector != "" {
+			fieldSelector += "," + opts.FieldSelector
+		}
+		opts.FieldSelector = fieldSelector
+	}
+	if opts.FieldSelector != "" {
+		// not all resources support arbitrary field selectors, fail fast instead of retrying the list forever
+		if _, err := resourceClient.List(metav1.ListOptions{LabelSelector: opts.LabelSelector, FieldSelector: opts.FieldSelector, Limit: 1}); err != nil {
+			return nil, fmt.Errorf("failed to list %s with field selector %q: %v", resourcePluralName, opts.FieldSelector, err)
+		}
+	}
+	var listWatcher resourceListWatcher = resourceClient
+	if i.Protobuf && i.restConfig != nil {
+		if protobuf, err := newProtobufResource(i.restConfig, mapping, namespace); err == nil {
+			listWatcher = protobuf
+		} else {
+			i.Logger.Info("using json instead of protobuf", "resource", resourcePluralName, "reason", err.Error())
+		}
+	}
+	indexers := cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc}
+	for name, indexFunc := range opts.Indexers {
+		indexers[name] = indexFunc
 	}
 	watch := &informerWatch{
-		name:     fmt.Sprintf("%s/%s %s", namespace, resourcePluralName, selector),
-		informer: i,
-		index:    len(i.watches),
-		watcher: cache.NewSharedIndexInformer(
-			newListWatcherFromResourceClient(resourceClient, selector),
-			&unstructured.Unstructured{},
-			resync,
-			cache.Indexers{},
+		WatchOpts:         opts,
+		opts:              watchOpts,
+		name:              fmt.Sprintf("%s/%s %s %s", namespace, resourcePluralName, opts.LabelSelector, opts.FieldSelector),
+		checkpointKey:     fmt.Sprintf("%s/%s/%s %s %s %s", mapping.Resource.Group, mapping.Resource.Version, resourcePluralName, namespace, opts.LabelSelector, opts.FieldSelector),
+		apiVersion:        apiVersion,
+		kind:              kind,
+		namespace:         namespace,
+		informer:          i,
+		excludeNamespaces: map[string]bool{},
+		limiter:           newEventLimiter(opts.EventRate, opts.EventBurst),
+		initialItems:      -1,
+	}
+	watch.watcher = cache.NewSharedIndexInformer(
+		withWatchTimeout(
+			withListedItems(
+				withWatchErrors(
+					withTransform(
+						withTransform(newListWatcherFromResourceClient(listWatcher, opts.LabelSelector, opts.FieldSelector, opts.ListChunkSize), setTypeMeta(apiVersion, kind)),
+						i.Transform,
+					),
+					watch.listed,
+					watch.watchError,
+				),
+				watch.initialListed,
+			),
+			opts.WatchTimeout,
 		),
+		&unstructured.Unstructured{},
+		opts.Resync,
+		indexers,
+	)
+	for _, ns := range opts.ExcludeNamespaces {
+		watch.excludeNamespaces[ns] = true
+	}
+	if opts.MaxConcurrent > 0 {
+		watch.concurrent = make(chan struct{}, opts.MaxConcurrent)
+	}
+	if opts.Transition != nil {
+		if err := opts.Transition.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	if i.Filter != "" {
+		if watch.filter, err = CompileFilter(i.Filter); err != nil {
+			return nil, err
+		}
 	}
 	watch.watcher.AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    watch.handleAdd,
 		DeleteFunc: watch.handleDelete,
 		UpdateFunc: watch.handleUpdate,
 	})
-	i.watches = append(i.watches, watch)
+	return watch, nil
+}
+
+func (i *informer) runWatch(watch *informerWatch) {
+	i.Logger.Info("watching", "watch", watch.name, "index", watch.index)
+	i.checkpoint.claim(watch.checkpointKey)
+	go watch.watcher.Run(watch.ctx.Done())
+}
+
+func (i *informer) StopWatch(index int) error {
+	watch, ok := i.watches.remove(index)
+	if !ok {
+		return fmt.Errorf("watch %d not found", index)
+	}
+	// stop is nil if the watch was never run
+	if watch.stop != nil {
+		watch.stop()
+	}
+	i.Logger.Info("stopped watching", "watch", watch.name, "index", watch.index)
 	return nil
 }
 
-func newListWatcherFromResourceClient(resourceClient dynamic.ResourceInterface, labelSelector string) *cache.ListWatch {
+func newListWatcherFromResourceClient(resourceClient resourceListWatcher, labelSelector string, fieldSelector string, chunkSize int64) *cache.ListWatch {
 	listFunc := func(options metav1.ListOptions) (runtime.Object, error) {
 		if labelSelector != "" {
 			options.LabelSelector = labelSelector
 		}
-		return resourceClient.List(options)
+		if fieldSelector != "" {
+			options.FieldSelector = fieldSelector
+		}
+		if chunkSize > 0 {
+			// lists from the watch cache (resourceVersion=0) are never chunked by apiserver,
+			// a consistent list from etcd is paginated by continue tokens of the same snapshot.
+			// the pager lists without limit if the snapshot expired while paginating
+			if options.Limit > 0 {
+				options.Limit = chunkSize
+			}
+			options.ResourceVersion = ""
+		}
+		list, err := resourceClient.List(options)
+		if err != nil {
+			// not a typed nil runtime.Object
+			return nil, err
+		}
+		return list, nil
 	}
 	watchFunc := func(options metav1.ListOptions) (watch.Interface, error) {
 		if labelSelector != "" {
 			options.LabelSelector = labelSelector
 		}
+		if fieldSelector != "" {
+			options.FieldSelector = fieldSelector
+		}
 		return resourceClient.Watch(options)
 	}
 	return &cache.ListWatch{ListFunc: listFunc, WatchFunc: watchFunc}
 }
 
-func (i *informer) Run(ctx context.Context) {
-	defer i.queue.ShutDown()
-	for _, watch := range i.watches {
-		logger.Printf("watching %s", watch.name)
-		go watch.watcher.Run(ctx.Done())
+// withListedItems calls listed with the number of objects of each list completed, summed over the chunks of paginated lists
+func withListedItems(lw *cache.ListWatch, listed func(items int)) *cache.ListWatch {
+	listFunc, items := lw.ListFunc, 0
+	lw.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		list, err := listFunc(options)
+		if err != nil {
+			return nil, err
+		}
+		if options.Continue == "" {
+			// the first chunk, or the list restarted by the pager
+			items = 0
+		}
+		if objects, err := meta.ExtractList(list); err == nil {
+			items += len(objects)
+		}
+		if listMeta, err := meta.ListAccessor(list); err == nil && listMeta.GetContinue() == "" {
+			listed(items)
+		}
+		return list, nil
+	}
+	return lw
+}
+
+// initialListed records the number of objects of the initial list, the later lists are ignored
+func (w *informerWatch) initialListed(items int) {
+	if atomic.CompareAndSwapInt64(&w.initialItems, -1, int64(items)) && items == 0 {
+		atomic.StoreInt32(&w.delivered, 1)
+	}
+}
+
+// addSynced returns false for the add events of the initial list, the adds after are synced.
+// The add events of the initial list are delivered first as the objects are queued by the list before the watch.
+func (w *informerWatch) addSynced() bool {
+	if atomic.LoadInt32(&w.delivered) == 1 {
+		return true
 	}
-	for _, watch := range i.watches {
-		if !cache.WaitForCacheSync(ctx.Done(), watch.watcher.HasSynced) {
-			panic("Timed out waiting for caches to sync")
+	adds, items := atomic.AddInt64(&w.initialAdds, 1), atomic.LoadInt64(&w.initialItems)
+	if items >= 0 && adds >= items {
+		atomic.StoreInt32(&w.delivered, 1)
+		return adds > items
+	}
+	return false
+}
+
+// eventSynced returns false for the events received before the add events of the initial list were all delivered
+func (w *informerWatch) eventSynced() bool {
+	return atomic.LoadInt32(&w.delivered) == 1
+}
+
+func (i *informer) Stop() {
+	i.stopLock.Lock()
+	defer i.stopLock.Unlock()
+	i.stopped = true
+	if i.stop != nil {
+		i.stop()
+	}
+}
+
+func (i *informer) Run(ctx context.Context) error {
+	err := i.run(ctx)
+	i.doneOnce.Do(func() {
+		i.runErr = err
+		close(i.done)
+	})
+	return err
+}
+
+func (i *informer) WaitForSync(ctx context.Context) error {
+	select {
+	case <-i.syncedCh:
+		return nil
+	default:
+	}
+	select {
+	case <-i.syncedCh:
+		return nil
+	case <-i.done:
+		select {
+		case <-i.syncedCh:
+			return nil
+		default:
+		}
+		if i.runErr != nil {
+			return i.runErr
 		}
+		return fmt.Errorf("informer stopped before synced")
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	go wait.Until(func() {
-		for i.processNextItem(ctx) {
+}
+
+func (i *informer) run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	i.stopLock.Lock()
+	stopped := i.stopped
+	i.stop = cancel
+	i.stopLock.Unlock()
+	if stopped {
+		i.queue.ShutDown()
+		i.closeEvents()
+		return nil
+	}
+	// the queue is shut down before the workers are stopped, ShutDown of the delaying queue can't be repeated
+	queueShutDown := sync.Once{}
+	shutDownQueue := func() {
+		queueShutDown.Do(i.queue.ShutDown)
+	}
+	defer shutDownQueue()
+	if i.queueLimit != nil {
+		defer i.queueLimit.shutDown()
+	}
+	if i.Metrics != nil {
+		if err := i.metrics.register(i.Metrics); err != nil {
+			i.Logger.Error("failed to register metrics", err)
 		}
-	}, time.Second, ctx.Done())
+		defer i.metrics.unregister(i.Metrics)
+	}
+	stopWorkers, workers := make(chan struct{}), sync.WaitGroup{}
+	defer func() {
+		go func() {
+			workers.Wait()
+			i.closeEvents()
+		}()
+	}()
+	if i.debouncer != nil {
+		go i.runDebouncer()
+		defer i.debouncer.shutDown()
+	}
+	if i.reconciler != nil {
+		go i.runReconciler()
+		defer i.reconciler.shutDown()
+	}
+	if i.DiscoveryRefreshInterval > 0 {
+		go i.runRefresh(ctx, i.DiscoveryRefreshInterval)
+	}
+	if i.TombstoneTTL > 0 {
+		go i.deletedObjects.runExpire(ctx, i.TombstoneTTL, i.Logger)
+		go i.firstSeen.runExpire(ctx, i.TombstoneTTL)
+	}
+	if i.CheckpointFile != "" {
+		checkpoint, err := loadCheckpoint(i.CheckpointFile)
+		if err != nil {
+			return fmt.Errorf("failed to load checkpoint: %v", err)
+		}
+		if i.CheckpointInterval <= 0 {
+			i.CheckpointInterval = 10 * time.Second
+		}
+		i.checkpoint = checkpoint
+		go checkpoint.run(ctx, i.CheckpointInterval, i.Logger)
+		defer func() {
+			if err := checkpoint.save(); err != nil {
+				i.Logger.Error("failed to save checkpoint", err, "file", i.CheckpointFile)
+			}
+		}()
+	}
+	watches := i.watches.start(ctx)
+	for _, watch := range watches {
+		i.runWatch(watch)
+	}
+	if err := i.waitForCacheSync(ctx, watches); err != nil {
+		if ctx.Err() != nil {
+			// stopped before synced (eg. leadership lost) is not a failure
+			i.Logger.Info("stopped before caches synced")
+			return nil
+		}
+		return err
+	}
+	for _, watch := range watches {
+		// watches stopped meanwhile are skipped
+		if watch.watcher.HasSynced() {
+			watch.initialSynced()
+		}
+	}
+	atomic.StoreInt32(&i.synced, 1)
+	defer atomic.StoreInt32(&i.synced, 0)
+	// workers are stopped separately from the watches to drain the queue on shutdown
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	if i.cluster != "" {
+		// passed to all handlers and callbacks of the events, see ClusterFromContext
+		workerCtx = context.WithValue(workerCtx, clusterContextKey{}, i.cluster)
+	}
+	var items chan eventKey
+	if i.BatchHandler != nil && i.fifo == nil {
+		items = make(chan eventKey)
+		go i.dispatch(workerCtx, items)
+	}
+	i.startWorkers(workerCtx, items, stopWorkers, &workers)
+	close(i.syncedCh)
 
 	<-ctx.Done()
-	logger.Printf("stopped all watch")
+	if i.queueLimit != nil {
+		// unblock the watches waiting for the queue
+		i.queueLimit.shutDown()
+	}
+	i.Logger.Info("stopped all watch")
+	pending := i.queueLen()
+	i.stopWorkers()
+	close(stopWorkers)
+	if !i.DrainOnShutdown {
+		// unblock the workers waiting in queue.Get and wait the handlers in progress to be cancelled
+		cancelWorkers()
+		shutDownQueue()
+		i.shutDownFIFO(false)
+		workers.Wait()
+		if pending > 0 {
+			i.Logger.Info("dropped queued events", "dropped", pending)
+		}
+		return nil
+	}
+
+	i.Logger.Info("draining queued events", "pending", pending)
+	shutDownQueue()
+	i.shutDownFIFO(true)
+	drained := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(drained)
+	}()
+	var timeout <-chan time.Time
+	if i.DrainTimeout > 0 {
+		timeout = time.After(i.DrainTimeout)
+	}
+	select {
+	case <-drained:
+	case <-timeout:
+		// wait the handlers in progress to be cancelled
+		cancelWorkers()
+		<-drained
+	}
+	dropped := i.queueLen()
+	i.Logger.Info("drained queued events", "drained", pending-dropped, "dropped", dropped)
+	return nil
+}
+
+// waitForCacheSync waits for the caches of the watches concurrently within SyncTimeout, watches stopped meanwhile are skipped
+func (i *informer) waitForCacheSync(ctx context.Context, watches []*informerWatch) error {
+	var syncCtx context.Context
+	var cancel context.CancelFunc
+	if i.SyncTimeout > 0 {
+		syncCtx, cancel = context.WithTimeout(ctx, i.SyncTimeout)
+	} else {
+		syncCtx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	errs, wg := make([]error, len(watches)), sync.WaitGroup{}
+	for n, watch := range watches {
+		wg.Add(1)
+		go func(n int, watch *informerWatch) {
+			defer wg.Done()
+			stop := make(chan struct{})
+			go func() {
+				defer close(stop)
+				select {
+				case <-syncCtx.Done():
+				case <-watch.ctx.Done():
+				}
+			}()
+			if !cache.WaitForCacheSync(stop, watch.watcher.HasSynced) && watch.ctx.Err() == nil {
+				errs[n] = fmt.Errorf("caches of %s not synced", watch.name)
+			}
+		}(n, watch)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		// stopped (eg. on shutdown), SyncTimeout is only applied to syncCtx
+		return err
+	}
+	if err := utilerrors.NewAggregate(errs); err != nil {
+		if i.PartialSync {
+			i.Logger.Error("caches not synced, handling the others", err, "timeout", i.SyncTimeout)
+			return nil
+		}
+		return fmt.Errorf("timed out waiting for caches to sync within %v: %v", i.SyncTimeout, err)
+	}
+	return nil
+}
+
+func (i *informer) WatchStatus() []WatchInfo {
+	watches := i.watches.list()
+	infos := make([]WatchInfo, 0, len(watches))
+	for _, watch := range watches {
+		infos = append(infos, WatchInfo{
+			Index:         watch.index,
+			Cluster:       i.cluster,
+			Name:          watch.name,
+			APIVersion:    watch.apiVersion,
+			Kind:          watch.kind,
+			Namespace:     watch.namespace,
+			LabelSelector: watch.LabelSelector,
+			FieldSelector: watch.FieldSelector,
+			Synced:        watch.watcher.HasSynced(),
+			Objects:       len(watch.watcher.GetIndexer().ListKeys()),
+		})
+	}
+	return infos
+}
+
+func (i *informer) ByIndex(index int, indexName string, indexValue string) ([]*unstructured.Unstructured, error) {
+	watch, ok := i.watches.get(index)
+	if !ok {
+		return nil, fmt.Errorf("watch %d not found", index)
+	}
+	objs, err := watch.watcher.GetIndexer().ByIndex(indexName, indexValue)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*unstructured.Unstructured, 0, len(objs))
+	for _, obj := range objs {
+		if obj, ok := obj.(*unstructured.Unstructured); ok {
+			ret = append(ret, obj)
+		}
+	}
+	return ret, nil
+}
+
+func (i *informer) Get(index int, namespace string, name string) (*unstructured.Unstructured, bool, error) {
+	watch, ok := i.watches.get(index)
+	if !ok {
+		return nil, false, fmt.Errorf("watch %d not found", index)
+	}
+	key := name
+	if namespace != "" {
+		key = namespace + "/" + name
+	}
+	obj, exists, err := watch.watcher.GetIndexer().GetByKey(key)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	ret, ok := obj.(*unstructured.Unstructured)
+	return ret, ok, nil
+}
+
+func (i *informer) Reprocess(index int, namespace string, name string) (bool, error) {
+	obj, exists, err := i.Get(index, namespace, name)
+	if err != nil || !exists {
+		return false, err
+	}
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return false, err
+	}
+	i.Logger.Info("reprocessing object", "key", key, "index", index)
+	if watch, ok := i.watches.get(index); ok && watch.pushed(EventUpdate, key, obj, nil, true) {
+		return true, nil
+	}
+	i.enqueue(eventKey{objectKey{index, key}, EventUpdate, true})
+	return true, nil
+}
+
+func (i *informer) Events() <-chan Event {
+	i.eventsLock.Lock()
+	defer i.eventsLock.Unlock()
+	if i.events == nil {
+		i.events = make(chan Event)
+	}
+	return i.events
+}
+
+func (i *informer) closeEvents() {
+	i.eventsLock.Lock()
+	defer i.eventsLock.Unlock()
+	if i.events != nil && !i.eventsClosed {
+		close(i.events)
+		i.eventsClosed = true
+	}
+}
+
+func (i *informer) emit(ctx context.Context, event Event) error {
+	i.eventsLock.Lock()
+	events := i.events
+	i.eventsLock.Unlock()
+	if events == nil {
+		return nil
+	}
+	select {
+	case events <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *informer) HasSynced() bool {
+	if atomic.LoadInt32(&i.synced) == 0 {
+		return false
+	}
+	for _, watch := range i.watches.list() {
+		if !watch.watcher.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (i *informer) Healthy() bool {
+	return atomic.LoadInt32(&i.synced) == 0 || atomic.LoadInt32(&i.liveWorkers) >= atomic.LoadInt32(&i.workers)
+}
+
+func (w *informerWatch) isExcluded(key string) bool {
+	namespace, _, err := cache.SplitMetaNamespaceKey(key)
+	return err == nil && namespace != "" && w.excludeNamespaces[namespace]
+}
+
+func (w *informerWatch) match(event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured) bool {
+	if w.Owner != nil && !w.Owner.Match(obj) {
+		return false
+	}
+	if len(w.AnnotationMatch) > 0 || len(w.AnnotationExclude) > 0 {
+		annotations := obj.GetAnnotations()
+		for key, value := range w.AnnotationMatch {
+			if !matchAnnotation(annotations, key, value) {
+				return false
+			}
+		}
+		for key, value := range w.AnnotationExclude {
+			if matchAnnotation(annotations, key, value) {
+				return false
+			}
+		}
+	}
+	if w.Transition != nil {
+		match, err := w.Transition.Match(event, obj, old)
+		if err != nil {
+			w.informer.Logger.Error("failed to evaluate transition", err, "event", event, "namespace", obj.GetNamespace(), "name", obj.GetName(), "watch", w.name)
+			return false
+		}
+		if !match {
+			return false
+		}
+	}
+	if w.filter == nil {
+		return true
+	}
+	match, err := w.filter.Match(event, obj, old)
+	if err != nil {
+		w.informer.Logger.Error("failed to evaluate filter", err, "event", event, "namespace", obj.GetNamespace(), "name", obj.GetName(), "watch", w.name)
+		return false
+	}
+	return match
+}
+
+// matchAnnotation returns true if the annotation of key exists with the value, or any value if empty
+func matchAnnotation(annotations map[string]string, key string, value string) bool {
+	v, ok := annotations[key]
+	return ok && (value == "" || v == value)
+}
+
+func (i *informer) enqueue(key eventKey) {
+	i.firstSeen.seen(key)
+	if i.reconciler != nil {
+		if key.event != EventDelete {
+			i.reconciler.add(key)
+			return
+		}
+		i.reconciler.forget(key.objectKey)
+	}
+	if i.debouncer != nil {
+		i.debouncer.add(key)
+		return
+	}
+	i.add(key)
+}
+
+func (i *informer) runReconciler() {
+	for {
+		key, ok := i.reconciler.next()
+		if !ok {
+			return
+		}
+		i.add(key)
+	}
+}
+
+func (i *informer) runDebouncer() {
+	for {
+		key, ok := i.debouncer.next()
+		if !ok {
+			return
+		}
+		if key.event == EventAdd {
+			// the object is created within the window, no state before
+			i.updatedObjects.remove(key.objectKey)
+		}
+		i.add(key)
+	}
+}
+
+func (w *informerWatch) invalidObject(event EventType, obj interface{}, err error) {
+	w.informer.Logger.Error("skipped invalid object", err, "event", event, "watch", w.name, "type", fmt.Sprintf("%T", obj))
+	w.informer.metrics.invalidObjects.WithLabelValues(string(event), w.name).Inc()
+}
+
+// unwrapDeletedObject returns the last known state of the object missed deletion when obj is a tombstone
+func unwrapDeletedObject(obj interface{}) (*unstructured.Unstructured, error) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		if tombstone.Obj == nil {
+			return nil, fmt.Errorf("no last known state in tombstone of %s", tombstone.Key)
+		}
+		obj = tombstone.Obj
+	}
+	deletedObj, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected object type %T", obj)
+	}
+	return deletedObj, nil
 }
 
 func (w *informerWatch) handleAdd(obj interface{}) {
+	// counted before skipped
+	synced := w.addSynced()
 	key, err := cache.MetaNamespaceKeyFunc(obj)
 	if err != nil {
-		panic(err)
+		w.invalidObject(EventAdd, obj, err)
+		return
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok && !synced {
+		if w.informer.checkpoint.handled(w.checkpointKey, key, u.GetResourceVersion()) {
+			// handled before restart
+			return
+		}
+		if w.unchanged(key, u) {
+			// cached by the watch replaced
+			return
+		}
+	}
+	if u, ok := obj.(*unstructured.Unstructured); ok && w.pushed(EventAdd, key, u, nil, synced) {
+		return
 	}
-	w.informer.queue.Add(eventKey{objectKey{w.index, key}, EventAdd})
+	w.informer.enqueue(eventKey{objectKey{w.index, key}, EventAdd, synced})
 }
 
 func (w *informerWatch) handleDelete(obj interface{}) {
 	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if w.informer.IgnoreDeletes {
+		// the reconcile state of the object is still released
+		if err == nil && w.informer.reconciler != nil {
+			w.informer.reconciler.forget(objectKey{w.index, key})
+		}
+		return
+	}
 	if err != nil {
-		panic(err)
+		w.invalidObject(EventDelete, obj, err)
+		return
 	}
-
-	w.informer.deletedObjects[objectKey{w.index, key}] = obj.(*unstructured.Unstructured).DeepCopy()
-	w.informer.queue.Add(eventKey{objectKey{w.index, key}, EventDelete})
+	deletedObj, err := unwrapDeletedObject(obj)
+	if err != nil {
+		w.invalidObject(EventDelete, obj, err)
+		return
+	}
+	if w.pushed(EventDelete, key, deletedObj, nil, w.eventSynced()) {
+		return
+	}
+	w.informer.deletedObjects.put(objectKey{w.index, key}, deletedObj.DeepCopy())
+	w.informer.enqueue(eventKey{objectKey{w.index, key}, EventDelete, w.eventSynced()})
 }
 
 func (w *informerWatch) handleUpdate(oldObj, newObj interface{}) {
 	key, err := cache.MetaNamespaceKeyFunc(newObj)
 	if err != nil {
-		panic(err)
+		w.invalidObject(EventUpdate, newObj, err)
+		return
+	}
+	old, ok := oldObj.(*unstructured.Unstructured)
+	if !ok {
+		w.invalidObject(EventUpdate, oldObj, fmt.Errorf("unexpected object type %T", oldObj))
+		return
+	}
+	if obj, ok := newObj.(*unstructured.Unstructured); ok && obj.GetResourceVersion() == old.GetResourceVersion() {
+		// periodic resync delivers the cached object unchanged
+		w.resynced()
+		if w.pushed(EventResync, key, obj, nil, w.eventSynced()) {
+			return
+		}
+		w.informer.enqueue(eventKey{objectKey{w.index, key}, EventResync, w.eventSynced()})
+		return
+	}
+	if obj, ok := newObj.(*unstructured.Unstructured); ok && w.GenerationChangesOnly && obj.GetGeneration() != 0 && obj.GetGeneration() == old.GetGeneration() {
+		return
 	}
-	w.informer.queue.Add(eventKey{objectKey{w.index, key}, EventUpdate})
+	if obj, ok := newObj.(*unstructured.Unstructured); ok && w.StatusChangesOnly && reflect.DeepEqual(obj.Object["status"], old.Object["status"]) {
+		return
+	}
+	if obj, ok := newObj.(*unstructured.Unstructured); ok && w.pushed(EventUpdate, key, obj, old, w.eventSynced()) {
+		return
+	}
+	if w.informer.reconciler == nil {
+		// the old state is not passed on reconcile
+		w.informer.updatedObjects.putIfAbsent(objectKey{w.index, key}, old.DeepCopy())
+	}
+	w.informer.enqueue(eventKey{objectKey{w.index, key}, EventUpdate, w.eventSynced()})
+}
+
+// queuedEvent is the event of a queue item to handle
+type queuedEvent struct {
+	eventKey
+	watch      *informerWatch
+	event      EventType
+	obj        *unstructured.Unstructured
+	old        *unstructured.Unstructured
+	exists     bool
+	numRetries int
+	// firstSeen is the time the event was first enqueued
+	firstSeen time.Time
+	// matched is false if the event is skipped by the filters of the watch
+	matched bool
+	// err is the error getting the object from the cache
+	err error
 }
 
 func (i *informer) processNextItem(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return false
+	}
 	item, quit := i.queue.Get()
 	if quit {
 		return false
 	}
+	key := item.(eventKey)
+	// the events of the same object are handled in order by the workers,
+	// the event waits for the event of the object handled by another worker
+	if !i.keyOrder.start(key) {
+		return true
+	}
+	for ok := true; ok; key, ok = i.keyOrder.next(key.objectKey) {
+		if parked := i.processItem(ctx, key); parked {
+			// the events waiting are handled after the event requeued
+			return true
+		}
+	}
+	return true
+}
+
+// processItem handles the event of the item dequeued, returns true if it is requeued as the watch is at MaxConcurrent
+func (i *informer) processItem(ctx context.Context, item eventKey) bool {
 	defer i.queue.Done(item)
-	eventKey, numRetries := item.(eventKey), i.queue.NumRequeues(item)
-	watcher := i.watches[eventKey.watchIndex].watcher
-	obj, exists, err := watcher.GetIndexer().GetByKey(eventKey.key)
-	if err == nil {
-		if !exists {
-			if _, ok := i.deletedObjects[eventKey.objectKey]; !ok {
-				logger.Printf("no last known state found for (%v)", eventKey)
-				i.queue.Forget(item)
-				return true
-			}
-			err = i.Handler(ctx, EventDelete, i.deletedObjects[eventKey.objectKey], numRetries)
+	e := i.resolve(ctx, item)
+	if e == nil {
+		return false
+	}
+	if e.err == nil && e.matched && e.watch.concurrent != nil {
+		select {
+		case e.watch.concurrent <- struct{}{}:
+			defer func() { <-e.watch.concurrent }()
+		default:
+			// requeue without counting a retry while the watch is at MaxConcurrent
+			i.restoreOld(e)
+			i.keyOrder.park(item)
+			i.queue.AddAfter(e.eventKey, concurrentRequeueDelay)
+			return true
+		}
+	}
+	err := e.err
+	if err == nil && e.matched {
+		if i.DryRun {
+			i.dryRun(e)
 		} else {
-			err = i.Handler(ctx, eventKey.event, obj.(*unstructured.Unstructured).DeepCopy(), numRetries)
+			err = i.handle(ctx, e.watch, e.event, e.obj, e.old, e.numRetries, e.synced)
 		}
 	}
-	if err != nil {
-		logger.Printf("error processing (%v, retries %v/%v): %v", eventKey, numRetries, i.MaxRetries, err)
-		if i.MaxRetries < 0 || numRetries < i.MaxRetries {
-			i.queue.AddRateLimited(item)
-			return true
+	i.complete(ctx, e, err)
+	return false
+}
+
+// resolve returns the event of the item to handle, or nil if the item is done
+func (i *informer) resolve(ctx context.Context, item eventKey) *queuedEvent {
+	watch, ok := i.watches.get(item.watchIndex)
+	if i.queueLimit != nil && i.queueLimit.taken(item) {
+		// dropped for a new event as the queue is full
+		ok = false
+	}
+	if !ok || watch.isExcluded(item.key) {
+		i.deletedObjects.remove(item.objectKey)
+		i.updatedObjects.remove(item.objectKey)
+		i.forget(item)
+		return nil
+	}
+	e := &queuedEvent{eventKey: item, watch: watch, event: item.event, numRetries: i.queue.NumRequeues(item), firstSeen: i.firstSeen.seen(item)}
+	if item.event == EventUpdate {
+		e.old = i.updatedObjects.take(item.objectKey)
+	}
+	obj, exists, err := watch.watcher.GetIndexer().GetByKey(item.key)
+	if e.exists, e.err = exists, err; err != nil {
+		return e
+	}
+	if !exists && item.event != EventDelete {
+		// the object is deleted before handled, the add or update is coalesced into the delete event queued since
+		i.forget(item)
+		return nil
+	}
+	if exists && item.event == EventAdd && !item.synced && i.SkipOlderThan > 0 {
+		if created := obj.(*unstructured.Unstructured).GetCreationTimestamp(); time.Since(created.Time) > i.SkipOlderThan {
+			i.forget(item)
+			return nil
 		}
 	}
 	if !exists {
-		delete(i.deletedObjects, eventKey.objectKey)
+		deletedObj, ok := i.deletedObjects.get(item.objectKey)
+		if !ok {
+			i.Logger.Info("no last known state found", "event", item.event, "key", item.key, "watch", watch.name)
+			i.forget(item)
+			return nil
+		}
+		e.obj = deletedObj
+	} else {
+		e.obj = obj.(*unstructured.Unstructured)
 	}
-	i.queue.Forget(item)
-	return true
+	if i.MaxObjectBytes > 0 {
+		if size := objectSize(e.obj.Object); size > i.MaxObjectBytes {
+			i.Logger.Info("skipped oversized object", "event", item.event, "key", item.key, "watch", watch.name, "size", size, "maxObjectBytes", i.MaxObjectBytes)
+			i.metrics.oversized.WithLabelValues(string(item.event), watch.name).Inc()
+			// completed as not matched
+			return e
+		}
+	}
+	if exists && !i.HandlerReadOnly {
+		e.obj = e.obj.DeepCopy()
+	}
+	if e.matched = watch.match(e.event, e.obj, e.old); e.matched {
+		if err := i.throttle(ctx, watch); err != nil {
+			// requeue without counting a retry
+			i.restoreOld(e)
+			i.queue.Add(item)
+			return nil
+		}
+	}
+	return e
+}
+
+// restoreOld puts back the old object of the update event to requeue
+func (i *informer) restoreOld(e *queuedEvent) {
+	if e.old != nil {
+		i.updatedObjects.put(e.objectKey, e.old)
+	}
+}
+
+// retryDelay returns the delay of the rate limiter before retrying the event, at least the delay of RetryAfterError
+func (i *informer) retryDelay(e *queuedEvent, err error) time.Duration {
+	delay := i.RateLimiter.When(e.eventKey)
+	if retryAfter, ok := err.(*retryAfterError); ok && retryAfter.delay > delay {
+		delay = retryAfter.delay
+	}
+	return delay
+}
+
+// complete retries the event if failed, or forgets the item
+func (i *informer) complete(ctx context.Context, e *queuedEvent, err error) {
+	if err != nil {
+		i.Logger.Error("error processing", err, "event", e.event, "key", e.key, "watch", e.watch.name, "retries", e.numRetries, "maxRetries", i.getMaxRetries())
+		if i.retryable(e, err) {
+			i.restoreOld(e)
+			// counted as AddRateLimited
+			i.queue.AddAfter(e.eventKey, i.retryDelay(e, err))
+			return
+		}
+		info := DropInfo{Retries: e.numRetries, FirstSeen: e.firstSeen, Elapsed: time.Since(e.firstSeen)}
+		i.Logger.Info("dropped event", "event", e.event, "key", e.key, "watch", e.watch.name, "retries", info.Retries, "firstSeen", info.FirstSeen.Format(time.RFC3339), "elapsed", info.Elapsed.String())
+		if i.OnDropped != nil {
+			i.OnDropped(ctx, e.event, e.obj, err, info)
+		}
+	}
+	if !e.exists {
+		i.deletedObjects.remove(e.objectKey)
+		if !i.DryRun {
+			i.checkpoint.record(e.watch.checkpointKey, e.key, nil)
+		}
+	} else if e.obj != nil && !i.DryRun {
+		i.checkpoint.record(e.watch.checkpointKey, e.key, e.obj)
+	}
+	i.forget(e.eventKey)
+}
+
+// retryable returns true if the failed event is retried by RetryPolicy and MaxRetries
+func (i *informer) retryable(e *queuedEvent, err error) bool {
+	_, permanent := err.(*permanentError)
+	maxRetries := i.getMaxRetries()
+	return !permanent && i.RetryPolicy != AtMostOnce && (maxRetries < 0 || e.numRetries < maxRetries)
+}
+
+// forget forgets the retries of the event done
+func (i *informer) forget(key eventKey) {
+	i.queue.Forget(key)
+	i.firstSeen.remove(key)
+}
+
+// objectSize estimates the json size of the object without marshaling
+func objectSize(value interface{}) int {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		size := 2
+		for key, field := range value {
+			size += len(key) + 4 + objectSize(field)
+		}
+		return size
+	case []interface{}:
+		size := 2
+		for _, item := range value {
+			size += objectSize(item) + 1
+		}
+		return size
+	case string:
+		return len(value) + 2
+	case nil:
+		return 4
+	}
+	// numbers and booleans
+	return 8
+}
+
+func newEventLimiter(eventRate float64, eventBurst int) *rate.Limiter {
+	if eventRate <= 0 {
+		return nil
+	}
+	if eventBurst < 1 {
+		eventBurst = 1
+	}
+	return rate.NewLimiter(rate.Limit(eventRate), eventBurst)
+}
+
+// throttle waits for the event limiters of the informer and the watch
+func (i *informer) throttle(ctx context.Context, watch *informerWatch) error {
+	for _, limiter := range []*rate.Limiter{watch.limiter, i.limiter} {
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// handlerFor returns false if the events of the type are skipped
+func (i *informer) handlerFor(event EventType) (func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error, bool) {
+	if i.EventHandler != nil {
+		return i.callEventHandler, true
+	}
+	if i.OnAdd == nil && i.OnUpdate == nil && i.OnDelete == nil {
+		return i.Handler, true
+	}
+	switch {
+	case event == EventAdd && i.OnAdd != nil:
+		return func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			return i.OnAdd(ctx, obj, numRetries, synced)
+		}, true
+	case (event == EventUpdate || event == EventResync || event == EventReconcile) && i.OnUpdate != nil:
+		return func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			return i.OnUpdate(ctx, obj, old, numRetries, synced)
+		}, true
+	case event == EventDelete && i.OnDelete != nil:
+		return func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			return i.OnDelete(ctx, obj, numRetries, synced)
+		}, true
+	}
+	return nil, false
+}
+
+// callWithTimeout calls the handler with the ctx bounded by HandlerTimeout
+func (i *informer) callWithTimeout(ctx context.Context, call func(ctx context.Context) error) error {
+	if i.HandlerTimeout <= 0 {
+		return call(ctx)
+	}
+	handlerCtx, cancel := context.WithTimeout(ctx, i.HandlerTimeout)
+	defer cancel()
+	err := call(handlerCtx)
+	if err != nil && ctx.Err() == nil && handlerCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("handler timed out after %v: %v", i.HandlerTimeout, err)
+	}
+	return err
+}
+
+// callEventHandler calls EventHandler with the event, the watch and the diff are passed by handle in ctx
+func (i *informer) callEventHandler(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+	return i.EventHandler(ctx, Event{
+		Type:            event,
+		Object:          obj,
+		OldObject:       old,
+		Cluster:         i.cluster,
+		Watch:           WatchNameFromContext(ctx),
+		LastAppliedDiff: LastAppliedDiffFromContext(ctx),
+		Retries:         numRetries,
+		Synced:          synced,
+	})
+}
+
+// dryRun logs the event would be handled
+func (i *informer) dryRun(e *queuedEvent) {
+	i.Logger.Info("dry run", "event", e.event, "key", e.key, "watch", e.watch.name, "name", e.obj.GetName(), "retries", e.numRetries, "synced", e.synced)
+	i.metrics.dryRunEvents.WithLabelValues(string(e.event), e.watch.name).Inc()
+}
+
+func (i *informer) handle(ctx context.Context, watch *informerWatch, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) (err error) {
+	handler, ok := i.handlerFor(event)
+	if watch.Handler != nil {
+		handler, ok = watch.Handler, true
+	}
+	if !ok {
+		return nil
+	}
+	if i.Tracer != nil {
+		var span Span
+		ctx, span = i.startSpan(ctx, "handle "+string(event), map[string]string{
+			"event":      string(event),
+			"watch":      watch.name,
+			"apiVersion": obj.GetAPIVersion(),
+			"kind":       obj.GetKind(),
+			"namespace":  obj.GetNamespace(),
+			"name":       obj.GetName(),
+			"retries":    strconv.Itoa(numRetries),
+		})
+		defer func() {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+		}()
+	}
+	start := time.Now()
+	diff := watch.lastAppliedDiff(event, obj, old)
+	if handler != nil {
+		handlerCtx := withEventSource(ctx, watch, obj)
+		if diff != nil {
+			handlerCtx = context.WithValue(handlerCtx, lastAppliedDiffContextKey{}, diff)
+		}
+		err = i.callWithTimeout(handlerCtx, func(ctx context.Context) error {
+			return handler(ctx, event, obj, old, numRetries, synced)
+		})
+	}
+	if err == nil {
+		err = i.emit(ctx, Event{Type: event, Object: obj, OldObject: old, Cluster: i.cluster, Watch: watch.name, Retries: numRetries, Synced: synced, LastAppliedDiff: diff})
+	}
+	i.metrics.handlerDuration.WithLabelValues(string(event), watch.name).Observe(time.Since(start).Seconds())
+	i.metrics.events.WithLabelValues(string(event), watch.name).Inc()
+	if err != nil {
+		i.metrics.handlerErrors.WithLabelValues(string(event), watch.name).Inc()
+	}
+	return err
 }
@@ -0,0 +1,689 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xiaopal/kube-informer/pkg/logging"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// newTestInformer returns the informer with a watch of configmaps not run, the objects are put in its cache by the tests
+func newTestInformer(opts InformerOpts) (*informer, *informerWatch) {
+	if opts.Logger == nil {
+		opts.Logger = logging.NewTextLogger(ioutil.Discard, "test")
+	}
+	i := NewInformerWithClients(nil, nil, opts).(*informer)
+	watch := &informerWatch{
+		name:              "default/configmaps  ",
+		apiVersion:        "v1",
+		kind:              "ConfigMap",
+		namespace:         "default",
+		informer:          i,
+		excludeNamespaces: map[string]bool{},
+		watcher:           cache.NewSharedIndexInformer(&cache.ListWatch{}, &unstructured.Unstructured{}, 0, cache.Indexers{}),
+	}
+	i.watches.add(watch)
+	return i, watch
+}
+
+// fakeWatch makes the watch list the objects and then receive the events of the fake watcher, for the tests running the informer
+func fakeWatch(w *informerWatch, objs ...*unstructured.Unstructured) *watch.FakeWatcher {
+	fake := watch.NewFake()
+	w.initialItems = -1
+	w.watcher = cache.NewSharedIndexInformer(withListedItems(withTransform(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			list := &unstructured.UnstructuredList{}
+			list.SetResourceVersion("1")
+			for _, obj := range objs {
+				list.Items = append(list.Items, *obj.DeepCopy())
+			}
+			return list, nil
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return fake, nil
+		},
+	}, setTypeMeta(w.apiVersion, w.kind)), w.initialListed), &unstructured.Unstructured{}, 0, cache.Indexers{})
+	w.watcher.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    w.handleAdd,
+		DeleteFunc: w.handleDelete,
+		UpdateFunc: w.handleUpdate,
+	})
+	return fake
+}
+
+func newConfigMap(name string, resourceVersion string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion("v1")
+	obj.SetKind("ConfigMap")
+	obj.SetNamespace("default")
+	obj.SetName(name)
+	obj.SetResourceVersion(resourceVersion)
+	return obj
+}
+
+// runWorkers processes the queue by the workers until it is shut down
+func runWorkers(ctx context.Context, i *informer, workers int) *sync.WaitGroup {
+	wg := &sync.WaitGroup{}
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i.processNextItem(ctx) {
+			}
+		}()
+	}
+	return wg
+}
+
+func TestWorkersKeepOrderOfObject(t *testing.T) {
+	events := []EventType{EventAdd, EventUpdate, EventResync, EventReconcile}
+	objects, handled := 50, sync.WaitGroup{}
+	lock, got := sync.Mutex{}, map[string][]EventType{}
+	i, watch := newTestInformer(InformerOpts{
+		Workers: 8,
+		Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			time.Sleep(time.Duration(rand.Intn(500)) * time.Microsecond)
+			lock.Lock()
+			got[obj.GetName()] = append(got[obj.GetName()], event)
+			lock.Unlock()
+			handled.Done()
+			return nil
+		},
+	})
+	for n := 0; n < objects; n++ {
+		if err := watch.watcher.GetIndexer().Add(newConfigMap(fmt.Sprintf("cm-%d", n), "1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handled.Add(objects * len(events))
+	workers := runWorkers(context.Background(), i, i.Workers)
+	// producers enqueue the events of each object in order, interleaved with the other objects
+	producers := sync.WaitGroup{}
+	for p := 0; p < 4; p++ {
+		producers.Add(1)
+		go func(p int) {
+			defer producers.Done()
+			for n := p; n < objects; n += 4 {
+				for _, event := range events {
+					i.enqueue(eventKey{objectKey{watch.index, fmt.Sprintf("default/cm-%d", n)}, event, true})
+				}
+			}
+		}(p)
+	}
+	producers.Wait()
+	handled.Wait()
+	i.queue.ShutDown()
+	workers.Wait()
+	for n := 0; n < objects; n++ {
+		if name := fmt.Sprintf("cm-%d", n); !reflect.DeepEqual(got[name], events) {
+			t.Errorf("events of %s handled in order %v, expected %v", name, got[name], events)
+		}
+	}
+}
+
+func BenchmarkHandle(b *testing.B) {
+	for _, readOnly := range []bool{false, true} {
+		name := "copy"
+		if readOnly {
+			name = "read-only"
+		}
+		b.Run(name, func(b *testing.B) {
+			i, watch := newTestInformer(InformerOpts{
+				HandlerReadOnly: readOnly,
+				Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+					return nil
+				},
+			})
+			obj := newConfigMap("cm", "1")
+			data := map[string]interface{}{}
+			for n := 0; n < 100; n++ {
+				data[fmt.Sprintf("key-%d", n)] = fmt.Sprintf("value-%d", n)
+			}
+			obj.Object["data"] = data
+			if err := watch.watcher.GetIndexer().Add(obj); err != nil {
+				b.Fatal(err)
+			}
+			key := eventKey{objectKey{watch.index, "default/cm"}, EventResync, true}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				i.queue.Add(key)
+				i.processNextItem(context.Background())
+			}
+		})
+	}
+}
+
+func TestRetryAfterErrorDelaysRetry(t *testing.T) {
+	calls := make(chan time.Time, 2)
+	i, watch := newTestInformer(InformerOpts{
+		MaxRetries: 1,
+		Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			calls <- time.Now()
+			if numRetries == 0 {
+				return RetryAfterError(fmt.Errorf("too many requests"), 200*time.Millisecond)
+			}
+			return nil
+		},
+	})
+	if err := watch.watcher.GetIndexer().Add(newConfigMap("cm", "1")); err != nil {
+		t.Fatal(err)
+	}
+	workers := runWorkers(context.Background(), i, 1)
+	defer workers.Wait()
+	defer i.queue.ShutDown()
+	i.enqueue(eventKey{objectKey{watch.index, "default/cm"}, EventAdd, true})
+	first, retried := <-calls, <-calls
+	if delay := retried.Sub(first); delay < 200*time.Millisecond {
+		t.Errorf("retried after %v, expected at least the Retry-After", delay)
+	}
+}
+
+func TestRetryAfterErrorDelaysRetryNoCoalesce(t *testing.T) {
+	calls := make(chan time.Time, 2)
+	i, watch := newTestInformer(InformerOpts{
+		MaxRetries: 1,
+		NoCoalesce: true,
+		Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			calls <- time.Now()
+			if numRetries == 0 {
+				return RetryAfterError(fmt.Errorf("too many requests"), 200*time.Millisecond)
+			}
+			return nil
+		},
+	})
+	if err := watch.watcher.GetIndexer().Add(newConfigMap("cm", "1")); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for i.processNextEvent(ctx, i.fifo[0]) {
+		}
+	}()
+	defer i.shutDownFIFO(false)
+	watch.pushed(EventAdd, "default/cm", newConfigMap("cm", "1"), nil, true)
+	first, retried := <-calls, <-calls
+	if delay := retried.Sub(first); delay < 200*time.Millisecond {
+		t.Errorf("retried after %v, expected at least the Retry-After", delay)
+	}
+}
+
+func TestDroppedUpdateReleasesOldObject(t *testing.T) {
+	i, watch := newTestInformer(InformerOpts{MaxQueueLength: 1, QueuePolicy: QueueDropNewest})
+	for n, name := range []string{"cm-1", "cm-2"} {
+		old, obj := newConfigMap(name, "1"), newConfigMap(name, "2")
+		if err := watch.watcher.GetIndexer().Add(obj); err != nil {
+			t.Fatal(err)
+		}
+		watch.handleUpdate(old, obj)
+		if n == 0 && i.updatedObjects.len() != 1 {
+			t.Fatalf("old object of the update queued not kept")
+		}
+	}
+	// the update of cm-2 is dropped as the queue is full
+	if got := i.updatedObjects.len(); got != 1 {
+		t.Errorf("%d old objects kept, expected only the update queued", got)
+	}
+	if _, ok := i.updatedObjects.get(objectKey{watch.index, "default/cm-2"}); ok {
+		t.Errorf("old object of the dropped update kept")
+	}
+}
+
+func TestInitialListEventsNotSynced(t *testing.T) {
+	i, watch := newTestInformer(InformerOpts{})
+	watch.initialItems = -1
+	// the initial list of 2 objects in 2 chunks
+	lw := withListedItems(&cache.ListWatch{ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+		list := &unstructured.UnstructuredList{}
+		if options.Continue == "" {
+			list.SetContinue("next")
+			list.Items = append(list.Items, *newConfigMap("cm-1", "1"))
+		} else {
+			list.Items = append(list.Items, *newConfigMap("cm-2", "1"))
+		}
+		return list, nil
+	}}, watch.initialListed)
+	for _, options := range []metav1.ListOptions{{}, {Continue: "next"}} {
+		if _, err := lw.ListFunc(options); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, obj := range []*unstructured.Unstructured{newConfigMap("cm-1", "1"), newConfigMap("cm-2", "1"), newConfigMap("cm-3", "1")} {
+		if err := watch.watcher.GetIndexer().Add(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	watch.handleAdd(newConfigMap("cm-1", "1"))
+	watch.handleUpdate(newConfigMap("cm-1", "1"), newConfigMap("cm-1", "2"))
+	watch.handleAdd(newConfigMap("cm-2", "1"))
+	// after the initial list delivered
+	watch.handleAdd(newConfigMap("cm-3", "1"))
+	watch.handleDelete(newConfigMap("cm-2", "1"))
+	expected := []eventKey{
+		{objectKey{watch.index, "default/cm-1"}, EventAdd, false},
+		{objectKey{watch.index, "default/cm-1"}, EventUpdate, false},
+		{objectKey{watch.index, "default/cm-2"}, EventAdd, false},
+		{objectKey{watch.index, "default/cm-3"}, EventAdd, true},
+		{objectKey{watch.index, "default/cm-2"}, EventDelete, true},
+	}
+	if i.queue.Len() != len(expected) {
+		t.Fatalf("%d events queued, expected %d", i.queue.Len(), len(expected))
+	}
+	for _, key := range expected {
+		item, _ := i.queue.Get()
+		if item.(eventKey) != key {
+			t.Errorf("queued %+v, expected %+v", item, key)
+		}
+		i.queue.Done(item)
+	}
+}
+
+func TestRunReturnsWhenCancelled(t *testing.T) {
+	for _, drain := range []bool{false, true} {
+		started, cancelled := make(chan struct{}, 1), make(chan struct{}, 1)
+		i, w := newTestInformer(InformerOpts{
+			DrainOnShutdown: drain,
+			DrainTimeout:    100 * time.Millisecond,
+			Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+				started <- struct{}{}
+				// blocked until the handler is cancelled
+				<-ctx.Done()
+				cancelled <- struct{}{}
+				return ctx.Err()
+			},
+		})
+		fakeWatch(w, newConfigMap("cm-1", "1"), newConfigMap("cm-2", "1"))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- i.Run(ctx)
+		}()
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for the handler")
+		}
+		cancel()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Run not returned after cancelled (drain: %v)", drain)
+		}
+		select {
+		case <-cancelled:
+		default:
+			t.Errorf("handler not cancelled (drain: %v)", drain)
+		}
+	}
+}
+
+func TestRunCancelledBeforeSynced(t *testing.T) {
+	i, w := newTestInformer(InformerOpts{})
+	// never synced as the list fails
+	w.watcher = cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			return nil, fmt.Errorf("forbidden")
+		},
+	}, &unstructured.Unstructured{}, 0, cache.Indexers{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- i.Run(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("cancelled before synced: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run not returned once cancelled")
+	}
+	// the cancellation is told from the timeout
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if err := i.waitForCacheSync(ctx, []*informerWatch{w}); err != context.Canceled {
+		t.Errorf("waited for the cancelled caches: %v", err)
+	}
+}
+
+func TestClusterInContextOfCallbacks(t *testing.T) {
+	clusters := make(chan string, 10)
+	callback := func(ctx context.Context) error {
+		clusters <- ClusterFromContext(ctx)
+		return nil
+	}
+	for _, opts := range []InformerOpts{
+		{
+			OnAdd: func(ctx context.Context, obj *unstructured.Unstructured, numRetries int, synced bool) error {
+				return callback(ctx)
+			},
+			OnDelete: func(ctx context.Context, obj *unstructured.Unstructured, numRetries int, synced bool) error {
+				return callback(ctx)
+			},
+		},
+		{
+			BatchSize: 1,
+			BatchHandler: func(ctx context.Context, events []Event) error {
+				return callback(ctx)
+			},
+		},
+	} {
+		i, w := newTestInformer(opts)
+		i.cluster = "cluster-1"
+		fake := fakeWatch(w, newConfigMap("cm-1", "1"))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- i.Run(ctx)
+		}()
+		expectCluster := func() {
+			select {
+			case cluster := <-clusters:
+				if cluster != "cluster-1" {
+					t.Errorf("cluster %q in context, expected cluster-1", cluster)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for the event")
+			}
+		}
+		expectCluster()
+		fake.Delete(newConfigMap("cm-1", "2"))
+		expectCluster()
+		cancel()
+		if err := <-done; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeleteTombstone(t *testing.T) {
+	i, watch := newTestInformer(InformerOpts{})
+	// the object deleted while the watch was disconnected
+	watch.handleDelete(cache.DeletedFinalStateUnknown{Key: "default/cm-1", Obj: newConfigMap("cm-1", "2")})
+	if i.queue.Len() != 1 {
+		t.Fatalf("%d events queued, expected 1", i.queue.Len())
+	}
+	item, _ := i.queue.Get()
+	defer i.queue.Done(item)
+	key := item.(eventKey)
+	if key.objectKey != (objectKey{watch.index, "default/cm-1"}) || key.event != EventDelete {
+		t.Errorf("queued %+v, expected the delete event of default/cm-1", key)
+	}
+	if obj, ok := i.deletedObjects.get(key.objectKey); !ok || obj.GetResourceVersion() != "2" {
+		t.Errorf("deleted object %v not kept", obj)
+	}
+}
+
+func TestDroppedWithoutRetries(t *testing.T) {
+	var calls int32
+	dropped := make(chan error, 2)
+	i, watch := newTestInformer(InformerOpts{
+		MaxRetries: 0,
+		Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			atomic.AddInt32(&calls, 1)
+			return fmt.Errorf("failed")
+		},
+		OnDropped: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, err error, info DropInfo) {
+			if event != EventAdd || obj.GetName() != "cm-1" || info.Retries != 0 {
+				t.Errorf("dropped %s of %s after %d retries", event, obj.GetName(), info.Retries)
+			}
+			dropped <- err
+		},
+	})
+	if err := watch.watcher.GetIndexer().Add(newConfigMap("cm-1", "1")); err != nil {
+		t.Fatal(err)
+	}
+	workers := runWorkers(context.Background(), i, 1)
+	i.enqueue(eventKey{objectKey{watch.index, "default/cm-1"}, EventAdd, true})
+	select {
+	case err := <-dropped:
+		if err == nil || err.Error() != "failed" {
+			t.Errorf("dropped with %v, expected the error of the handler", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for OnDropped")
+	}
+	time.Sleep(100 * time.Millisecond)
+	i.queue.ShutDown()
+	workers.Wait()
+	if len(dropped) != 0 || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("handled %d times and dropped %d more times, expected once", atomic.LoadInt32(&calls), len(dropped))
+	}
+}
+
+func TestHandlerTimeoutRequeues(t *testing.T) {
+	calls := make(chan error, 2)
+	i, watch := newTestInformer(InformerOpts{
+		HandlerTimeout: 50 * time.Millisecond,
+		RetryBaseDelay: time.Millisecond,
+		MaxRetries:     1,
+		Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			if numRetries > 0 {
+				calls <- nil
+				return nil
+			}
+			// sleeps past the timeout unless cancelled
+			select {
+			case <-ctx.Done():
+				calls <- ctx.Err()
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+				calls <- fmt.Errorf("not cancelled")
+				return nil
+			}
+		},
+	})
+	if err := watch.watcher.GetIndexer().Add(newConfigMap("cm-1", "1")); err != nil {
+		t.Fatal(err)
+	}
+	workers := runWorkers(context.Background(), i, 1)
+	defer workers.Wait()
+	defer i.queue.ShutDown()
+	i.enqueue(eventKey{objectKey{watch.index, "default/cm-1"}, EventAdd, true})
+	for retries, expected := range []error{context.DeadlineExceeded, nil} {
+		select {
+		case err := <-calls:
+			if err != expected {
+				t.Errorf("handler of %d retries returned %v, expected %v", retries, err, expected)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for the handler of %d retries", retries)
+		}
+	}
+}
+
+func TestRapidAddDeleteOfObject(t *testing.T) {
+	lock, last := sync.Mutex{}, EventType("")
+	i, w := newTestInformer(InformerOpts{
+		Workers: 4,
+		Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			lock.Lock()
+			defer lock.Unlock()
+			last = event
+			return nil
+		},
+	})
+	fake := fakeWatch(w)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go i.Run(ctx)
+	if err := i.WaitForSync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the deleted objects are put by the watch and taken by the workers concurrently
+	for n := 0; n < 200; n++ {
+		fake.Add(newConfigMap("cm-1", fmt.Sprint(2*n+1)))
+		fake.Delete(newConfigMap("cm-1", fmt.Sprint(2*n+2)))
+	}
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		lock.Lock()
+		handled := last
+		lock.Unlock()
+		if handled == EventDelete && i.queue.Len() == 0 && i.deletedObjects.len() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("last event %q handled, %d events queued and %d deleted objects kept", handled, i.queue.Len(), i.deletedObjects.len())
+		}
+	}
+}
+
+func TestTypeMetaOfHandledObjects(t *testing.T) {
+	handled := make(chan string, 10)
+	i, w := newTestInformer(InformerOpts{
+		Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			handled <- fmt.Sprintf("%s %s/%s", event, obj.GetAPIVersion(), obj.GetKind())
+			return nil
+		},
+	})
+	// the items of lists and the objects of some watches come without apiVersion and kind
+	withoutTypeMeta := func(name string, resourceVersion string) *unstructured.Unstructured {
+		obj := newConfigMap(name, resourceVersion)
+		delete(obj.Object, "apiVersion")
+		delete(obj.Object, "kind")
+		return obj
+	}
+	fake := fakeWatch(w, withoutTypeMeta("cm-1", "1"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go i.Run(ctx)
+	expectHandled := func(expected string) {
+		select {
+		case event := <-handled:
+			if event != expected {
+				t.Errorf("handled %s, expected %s", event, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %s", expected)
+		}
+	}
+	expectHandled("add v1/ConfigMap")
+	fake.Modify(withoutTypeMeta("cm-1", "2"))
+	expectHandled("update v1/ConfigMap")
+	fake.Delete(withoutTypeMeta("cm-1", "3"))
+	expectHandled("delete v1/ConfigMap")
+}
+
+// newTestRESTMapper maps the core and apps kinds served by a cluster, preferring apps/v1
+func newTestRESTMapper() *meta.DefaultRESTMapper {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Version: "v1"}, {Group: "apps", Version: "v1"}, {Group: "apps", Version: "v1beta2"}})
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1beta2", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	return mapper
+}
+
+func newTestDynamicClient(t *testing.T) dynamic.Interface {
+	// not connected until requested
+	client, err := dynamic.NewForConfig(&rest.Config{Host: "127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestResourceMappingOfKinds(t *testing.T) {
+	i := NewInformerWithClients(newTestDynamicClient(t), newTestRESTMapper(), InformerOpts{Logger: logging.NewTextLogger(ioutil.Discard, "test")}).(*informer)
+	for _, c := range []struct {
+		apiVersion, kind, namespace string
+		expected                    schema.GroupVersionResource
+		expectedNamespace           string
+	}{
+		{"v1", "ConfigMap", "default", schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "default"},
+		{"core/v1", "ConfigMap", "default", schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "default"},
+		{"v1", "Namespace", "default", schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, ""},
+		{"apps/v1beta2", "Deployment", "default", schema.GroupVersionResource{Group: "apps", Version: "v1beta2", Resource: "deployments"}, "default"},
+		// the preferred version if not served or omitted
+		{"apps/v1beta1", "Deployment", "default", schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, "default"},
+		{"apps/", "Deployment", "", schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, ""},
+	} {
+		_, mapping, namespace, err := i.getResourceClient(c.apiVersion, c.kind, c.namespace)
+		if err != nil {
+			t.Errorf("%s %s: %v", c.apiVersion, c.kind, err)
+			continue
+		}
+		if mapping.Resource != c.expected || namespace != c.expectedNamespace {
+			t.Errorf("%s %s mapped to %v in %q, expected %v in %q", c.apiVersion, c.kind, mapping.Resource, namespace, c.expected, c.expectedNamespace)
+		}
+	}
+	if _, _, _, err := i.getResourceClient("v1", "Example", "default"); err == nil {
+		t.Error("unknown kind mapped")
+	}
+}
+
+// resettingRESTMapper discovers the kinds installed after startup once reset, as the deferred discovery REST mapper
+type resettingRESTMapper struct {
+	*meta.DefaultRESTMapper
+	installed []schema.GroupVersionKind
+	resets    int
+}
+
+func (m *resettingRESTMapper) Reset() {
+	m.resets++
+	for _, gvk := range m.installed {
+		m.Add(gvk, meta.RESTScopeNamespace)
+	}
+	m.installed = nil
+}
+
+func TestResourceMappingOfKindInstalled(t *testing.T) {
+	mapper := &resettingRESTMapper{DefaultRESTMapper: newTestRESTMapper()}
+	i := NewInformerWithClients(newTestDynamicClient(t), mapper, InformerOpts{Logger: logging.NewTextLogger(ioutil.Discard, "test")}).(*informer)
+	if _, _, _, err := i.getResourceClient("example.com/v1", "Example", "default"); err == nil {
+		t.Fatal("kind not installed mapped")
+	}
+	// eg. the CRD installed after startup
+	mapper.installed = append(mapper.installed, schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Example"})
+	mapper.resets = 0
+	_, mapping, _, err := i.getResourceClient("example.com/v1", "Example", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "examples"}); mapping.Resource != expected || mapper.resets != 1 {
+		t.Errorf("mapped to %v after %d resets, expected %v after 1 reset", mapping.Resource, mapper.resets, expected)
+	}
+	// known kinds are mapped without reset
+	if _, _, _, err := i.getResourceClient("v1", "ConfigMap", "default"); err != nil || mapper.resets != 1 {
+		t.Errorf("mapped ConfigMap after %d resets: %v", mapper.resets, err)
+	}
+}
+
+func TestReconcilerForgetsIgnoredDeletes(t *testing.T) {
+	i, watch := newTestInformer(InformerOpts{ReconcileInterval: time.Hour, IgnoreDeletes: true})
+	defer i.reconciler.shutDown()
+	watch.handleAdd(newConfigMap("cm-1", "1"))
+	if _, ok := i.reconciler.next(); !ok {
+		t.Fatal("not reconciled")
+	}
+	// dirty again, not due before the interval
+	watch.handleUpdate(newConfigMap("cm-1", "1"), newConfigMap("cm-1", "2"))
+	watch.handleDelete(newConfigMap("cm-1", "2"))
+	i.reconciler.lock.Lock()
+	defer i.reconciler.lock.Unlock()
+	if len(i.reconciler.last) != 0 || len(i.reconciler.dirty) != 0 {
+		t.Errorf("%d last and %d dirty objects kept after the ignored delete", len(i.reconciler.last), len(i.reconciler.dirty))
+	}
+}
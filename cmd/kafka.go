@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/xiaopal/kube-informer/pkg/kafka"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func handleKafkaEvent(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+	if !handlerEvents[event] {
+		return nil
+	}
+	key := &bytes.Buffer{}
+	if kafkaKeyTemplate != nil {
+		if err := kafkaKeyTemplate.Execute(key, obj.Object); err != nil {
+			return PermanentError(fmt.Errorf("failed to render kafka key: %v", err))
+		}
+	}
+	value, err := json.Marshal(&webhookEvent{
+		Cluster:         ClusterFromContext(ctx),
+		Event:           event,
+		Object:          obj,
+		OldObject:       old,
+		Retries:         numRetries,
+		Synced:          synced,
+		LastAppliedDiff: LastAppliedDiffFromContext(ctx),
+	})
+	if err != nil {
+		return PermanentError(fmt.Errorf("failed to marshal event: %v", err))
+	}
+	if headers := TraceHeaders(ctx); headers != nil {
+		ctx = kafka.WithHeaders(ctx, headers)
+	}
+	return kafkaClient.Send(ctx, key.String(), value)
+}
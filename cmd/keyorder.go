@@ -0,0 +1,79 @@
+package main
+
+import (
+	"hash/fnv"
+	"strconv"
+	"sync"
+)
+
+// keyOrder keeps the events of the same object in order across concurrent workers, as the queue only dedups the same event type.
+// An event dequeued while another event of the object is handled waits for it, and is handled next by the same worker
+// (still not done in the queue so that the same event enqueued meanwhile is coalesced). The events of different objects are handled in parallel.
+type keyOrder struct {
+	lock    sync.Mutex
+	running map[objectKey]*keyState
+}
+
+type keyState struct {
+	// waiting is the events dequeued while the object is handled, in the order dequeued
+	waiting []eventKey
+	// parked is the event requeued to handle later (see park), it is handled before the waiting events once dequeued again
+	parked *eventKey
+}
+
+func newKeyOrder() *keyOrder {
+	return &keyOrder{running: map[objectKey]*keyState{}}
+}
+
+// start returns true if the event is to handle now, or false if it waits for the event of the object being handled
+func (o *keyOrder) start(item eventKey) bool {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	state, ok := o.running[item.objectKey]
+	if !ok {
+		o.running[item.objectKey] = &keyState{}
+		return true
+	}
+	if state.parked != nil && *state.parked == item {
+		state.parked = nil
+		return true
+	}
+	state.waiting = append(state.waiting, item)
+	return false
+}
+
+// next returns the next event waiting for the object, or false if none so that the object is done
+func (o *keyOrder) next(key objectKey) (eventKey, bool) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	state, ok := o.running[key]
+	if !ok {
+		return eventKey{}, false
+	}
+	if len(state.waiting) == 0 {
+		delete(o.running, key)
+		return eventKey{}, false
+	}
+	item := state.waiting[0]
+	state.waiting = state.waiting[1:]
+	return item, true
+}
+
+// park keeps the object running while the event is requeued (eg. at WatchOpts.MaxConcurrent),
+// the events waiting are handled after the event dequeued again
+func (o *keyOrder) park(item eventKey) {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if state, ok := o.running[item.objectKey]; ok {
+		state.parked = &item
+	}
+}
+
+// keyShard hashes the object key to one of n shards
+func keyShard(key objectKey, n int) int {
+	h := fnv.New32a()
+	h.Write([]byte(strconv.Itoa(key.watchIndex)))
+	h.Write([]byte{'/'})
+	h.Write([]byte(key.key))
+	return int(h.Sum32() % uint32(n))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+//ConfigDiff type is the paths (eg. `spec.replicas`) changed between two configurations, lists are compared as a whole
+type ConfigDiff struct {
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+	Changed []string `json:"changed,omitempty"`
+}
+
+//Empty func
+func (d *ConfigDiff) Empty() bool {
+	return d == nil || len(d.Added)+len(d.Removed)+len(d.Changed) == 0
+}
+
+type lastAppliedDiffContextKey struct{}
+
+//LastAppliedDiffFromContext func returns the diff of the last-applied-configuration annotation passed to Handler on update events
+//if WatchOpts.DiffLastApplied is set, nil otherwise (eg. old object unknown or the annotation not parsed)
+func LastAppliedDiffFromContext(ctx context.Context) *ConfigDiff {
+	diff, _ := ctx.Value(lastAppliedDiffContextKey{}).(*ConfigDiff)
+	return diff
+}
+
+// lastAppliedDiff returns the diff of the last-applied-configuration annotation of the update event if enabled
+func (w *informerWatch) lastAppliedDiff(event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured) *ConfigDiff {
+	if !w.DiffLastApplied || event != EventUpdate || obj == nil || old == nil {
+		return nil
+	}
+	newConfig, err := lastAppliedConfig(obj)
+	if err != nil {
+		w.informer.Logger.Error("failed to parse last-applied-configuration", err, "key", obj.GetNamespace()+"/"+obj.GetName(), "watch", w.name)
+		return nil
+	}
+	oldConfig, err := lastAppliedConfig(old)
+	if err != nil {
+		w.informer.Logger.Error("failed to parse last-applied-configuration", err, "key", old.GetNamespace()+"/"+old.GetName(), "watch", w.name)
+		return nil
+	}
+	diff := &ConfigDiff{}
+	diffConfig(diff, "", oldConfig, newConfig)
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// lastAppliedConfig returns empty config if the annotation is absent
+func lastAppliedConfig(obj *unstructured.Unstructured) (map[string]interface{}, error) {
+	config := map[string]interface{}{}
+	if data, ok := obj.GetAnnotations()[lastAppliedAnnotation]; ok && data != "" {
+		if err := json.Unmarshal([]byte(data), &config); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+func diffConfig(diff *ConfigDiff, prefix string, old map[string]interface{}, new map[string]interface{}) {
+	for key, newValue := range new {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		oldValue, ok := old[key]
+		if !ok {
+			diff.Added = append(diff.Added, path)
+			continue
+		}
+		oldMap, oldIsMap := oldValue.(map[string]interface{})
+		newMap, newIsMap := newValue.(map[string]interface{})
+		if oldIsMap && newIsMap {
+			diffConfig(diff, path, oldMap, newMap)
+		} else if !reflect.DeepEqual(oldValue, newValue) {
+			diff.Changed = append(diff.Changed, path)
+		}
+	}
+	for key := range old {
+		if _, ok := new[key]; !ok {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			diff.Removed = append(diff.Removed, path)
+		}
+	}
+}
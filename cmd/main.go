@@ -2,40 +2,176 @@ package main
 
 import (
 	"context"
+	"net/http"
 	"os"
+	"sync/atomic"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/xiaopal/kube-informer/pkg/appctx"
 	"github.com/xiaopal/kube-informer/pkg/subreaper"
+	"k8s.io/client-go/rest"
 )
 
+// runFailed is set if runInformer fails, to exit non-zero
+var runFailed int32
+
 func runInformer(ctx context.Context) {
 	config, err := kubeClient.GetConfig()
 	if err != nil {
-		logger.Printf("failed to get config: %v", err)
+		logger.Error("failed to get config", err)
+		atomic.StoreInt32(&runFailed, 1)
 		return
 	}
-	informer := NewInformer(config, InformerOpts{
-		Handler:     handleEvent,
-		MaxRetries:  handlerMaxRetries,
-		RateLimiter: handlerRateLimiter(),
-	})
+	handler := handleEvent
+	switch handlerType {
+	case "webhook":
+		handler = handleWebhookEvent
+	case "log":
+		handler = handleLogEvent
+	case "kafka":
+		handler = handleKafkaEvent
+		defer kafkaClient.Close()
+	case "nats":
+		handler = handleNATSEvent
+		defer natsClient.Close()
+	case "redis":
+		handler = handleRedisEvent
+		defer redisClient.Close()
+	case "file":
+		handler = handleFileEvent
+		defer fileClient.Close()
+	}
+	opts := InformerOpts{
+		Handler:            handler,
+		MaxRetries:         handlerMaxRetries,
+		RetryPolicy:        RetryPolicy(retryPolicy),
+		RateLimiter:        handlerRateLimiter(),
+		Workers:            handlerWorkers,
+		Metrics:            metricsRegistry,
+		QueueName:          "informer",
+		DrainOnShutdown:    handlerDrain,
+		DrainTimeout:       handlerDrainTimeout,
+		Filter:             eventFilter,
+		DebounceWindow:     handlerDebounce,
+		ReconcileInterval:  reconcileInterval,
+		EventRate:          handlerEventRate,
+		EventBurst:         handlerEventBurst,
+		CheckpointFile:     checkpointFile,
+		CheckpointInterval: checkpointInterval,
+		SyncTimeout:        syncTimeout,
+		PartialSync:        partialSync,
+		AccessCheck:        AccessCheck(accessCheck),
+		SkipOlderThan:      skipOlderThan,
+		TombstoneTTL:       tombstoneTTL,
+		IgnoreDeletes:      !handlerEvents[EventDelete],
+		DryRun:             dryRun,
+		MaxObjectBytes:     maxObjectBytes,
+		MaxQueueLength:     maxQueueLength,
+		QueuePolicy:        QueuePolicy(queuePolicy),
+		NoCoalesce:         noCoalesce,
+		HandlerReadOnly:    handlerReadOnly,
+		FieldManager:       fieldManager,
+		Protobuf:           protobuf,
+	}
+	opts.DiscoveryRefreshInterval = discoveryRefresh
+	atomic.StoreInt32(&execMaxRetries, int32(handlerMaxRetries))
+	if handlerType != "exec" {
+		// the exec handler is killed by itself
+		opts.HandlerTimeout = handlerTimeout
+	}
+	if fields := append([]string{}, dropFields...); dropManagedFields || len(fields) > 0 {
+		if dropManagedFields {
+			fields = append(fields, "metadata.managedFields")
+		}
+		opts.Transform = DropFields(fields...)
+	}
+	if droppedFile != "" {
+		opts.OnDropped = appendDroppedEvent
+	}
+	opts.OnWatchError = func(watch string, category WatchErrorCategory, err error) {
+		logger.Error("failed to list and watch", err, "watch", watch, "category", category)
+	}
+	opts.OnSynced = func(watch string) {
+		logger.Info("watch synced", "watch", watch)
+	}
+	var informer Informer
+	if len(clusterContexts) > 0 {
+		configs := map[string]*rest.Config{}
+		for _, kubeContext := range clusterContexts {
+			if configs[kubeContext], err = kubeClient.GetContextConfig(kubeContext); err != nil {
+				logger.Error("failed to get config", err, "context", kubeContext)
+				atomic.StoreInt32(&runFailed, 1)
+				return
+			}
+		}
+		informer = NewMultiInformer(configs, opts)
+	} else {
+		informer = NewInformer(config, opts)
+	}
 	for _, watch := range parsedWatches {
-		err := informer.Watch(watch["apiVersion"], watch["kind"], kubeClient.Namespace(), selector, resyncDuration)
-		if err != nil {
-			logger.Printf("failed to watch %v: %v", watch, err)
+		if _, err := addConfigWatch(informer, watch); err != nil {
+			logger.Error("failed to watch", err, "watch", watch.String())
+			atomic.StoreInt32(&runFailed, 1)
 			return
 		}
 	}
-	informer.Run(ctx)
-	<-ctx.Done()
+	if loadedConfig != nil {
+		reloader := newConfigReloader(informer, configFile, loadedConfig, configModTime)
+		for _, watch := range loadedConfig.Watches {
+			if err := reloader.add(watch); err != nil {
+				logger.Error("failed to watch", err, "watch", watch.String())
+				atomic.StoreInt32(&runFailed, 1)
+				return
+			}
+		}
+		if configReloadInterval > 0 {
+			go reloader.run(ctx, configReloadInterval)
+		}
+	}
+	if len(crdGroups) > 0 {
+		discovery := newCRDDiscovery(informer, crdGroups, defaultWatch)
+		go func() {
+			if err := discovery.run(ctx, config); err != nil {
+				logger.Error("failed to discover CRDs", err, "groups", crdGroups)
+			}
+		}()
+	}
+	setRunningInformer(informer)
+	defer setRunningInformer(nil)
+	if err := informer.Run(ctx); err != nil {
+		logger.Error("failed to run informer", err)
+		atomic.StoreInt32(&runFailed, 1)
+	}
 }
 
 func main() {
+	parseOptions()
 	app := appctx.Start()
 	defer app.End()
 
 	if os.Getpid() == 1 {
 		subreaper.Start(app.Context())
 	}
+	metricsRegistry = prometheus.NewRegistry()
+	metricsRegistry.MustRegister(prometheus.NewGoCollector(), prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}))
+	if err := RegisterWorkqueueMetrics(metricsRegistry); err != nil {
+		logger.Error("failed to register workqueue metrics", err)
+	}
+	if listenAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", MetricsHandler(metricsRegistry))
+		mux.Handle("/readyz", ReadyzHandler())
+		mux.Handle("/healthz", HealthzHandler())
+		mux.Handle("/watches", WatchesHandler())
+		if adminAPI {
+			mux.Handle("/reprocess", ReprocessHandler())
+		}
+		serveHTTP(app.Context(), listenAddr, mux)
+	}
 	leaderHelper.Run(app.Context(), runInformer)
+	if atomic.LoadInt32(&runFailed) != 0 {
+		app.End()
+		os.Exit(1)
+	}
+	logger.Info("shut down")
 }
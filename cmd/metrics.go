@@ -0,0 +1,231 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const metricsNamespace = "kube_informer"
+
+type informerMetrics struct {
+	queueLength     prometheus.GaugeFunc
+	tombstones      prometheus.GaugeFunc
+	events          *prometheus.CounterVec
+	handlerErrors   *prometheus.CounterVec
+	handlerDuration *prometheus.HistogramVec
+	invalidObjects  *prometheus.CounterVec
+	lists           *prometheus.CounterVec
+	watchErrors     *prometheus.CounterVec
+	dryRunEvents    *prometheus.CounterVec
+	oversized       *prometheus.CounterVec
+	queueDropped    *prometheus.CounterVec
+	resyncs         *prometheus.CounterVec
+	resyncObjects   *prometheus.GaugeVec
+}
+
+func newInformerMetrics(queueLength func() float64, tombstones func() float64) *informerMetrics {
+	return &informerMetrics{
+		queueLength: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "queue_length",
+			Help:      "Number of events waiting in the queue.",
+		}, queueLength),
+		tombstones: prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "tombstones",
+			Help:      "Number of last known states of deleted objects kept for the delete events.",
+		}, tombstones),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "events_total",
+			Help:      "Number of events passed to the handler, including retries.",
+		}, []string{"event", "watch"}),
+		handlerErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "handler_errors_total",
+			Help:      "Number of events the handler failed to process.",
+		}, []string{"event", "watch"}),
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Time spent in the handler.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 15),
+		}, []string{"event", "watch"}),
+		invalidObjects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "invalid_objects_total",
+			Help:      "Number of events skipped as the object has no valid key.",
+		}, []string{"event", "watch"}),
+		lists: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "lists_total",
+			Help:      "Number of list requests of the watch, including the initial list and relists.",
+		}, []string{"watch"}),
+		watchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "watch_errors_total",
+			Help:      "Number of errors of the list and watch requests, including error events of watches.",
+		}, []string{"watch", "op", "category"}),
+		dryRunEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "dry_run_events_total",
+			Help:      "Number of events would be passed to the handler in dry run mode.",
+		}, []string{"event", "watch"}),
+		oversized: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "oversized_objects_total",
+			Help:      "Number of events skipped as the object is larger than max object bytes.",
+		}, []string{"event", "watch"}),
+		queueDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "queue_dropped_events_total",
+			Help:      "Number of events dropped as the queue is full.",
+		}, []string{"event", "watch"}),
+		resyncs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "resyncs_total",
+			Help:      "Number of resync cycles of the watch replaying the cached objects, including the unchanged objects of relists.",
+		}, []string{"watch"}),
+		resyncObjects: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "resync_objects",
+			Help:      "Number of objects replayed by the last resync cycle of the watch.",
+		}, []string{"watch"}),
+	}
+}
+
+func (m *informerMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.queueLength, m.tombstones, m.events, m.handlerErrors, m.handlerDuration, m.invalidObjects, m.lists, m.watchErrors, m.dryRunEvents, m.oversized, m.queueDropped, m.resyncs, m.resyncObjects}
+}
+
+func (m *informerMetrics) register(registerer prometheus.Registerer) error {
+	for _, c := range m.collectors() {
+		if err := registerer.Register(c); err != nil {
+			m.unregister(registerer)
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *informerMetrics) unregister(registerer prometheus.Registerer) {
+	for _, c := range m.collectors() {
+		registerer.Unregister(c)
+	}
+}
+
+type workqueueMetrics struct {
+	depth        *prometheus.GaugeVec
+	adds         *prometheus.CounterVec
+	latency      *prometheus.HistogramVec
+	workDuration *prometheus.HistogramVec
+	retries      *prometheus.CounterVec
+}
+
+func newWorkqueueMetrics() *workqueueMetrics {
+	return &workqueueMetrics{
+		depth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "depth",
+			Help:      "Current depth of the workqueue.",
+		}, []string{"name"}),
+		adds: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "adds_total",
+			Help:      "Number of adds handled by the workqueue.",
+		}, []string{"name"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "queue_duration_seconds",
+			Help:      "Time an item stays in the workqueue before being processed.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"name"}),
+		workDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "work_duration_seconds",
+			Help:      "Time processing an item from the workqueue takes.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 10),
+		}, []string{"name"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "workqueue",
+			Name:      "retries_total",
+			Help:      "Number of retries handled by the workqueue.",
+		}, []string{"name"}),
+	}
+}
+
+func (m *workqueueMetrics) NewDepthMetric(name string) workqueue.GaugeMetric {
+	return m.depth.WithLabelValues(name)
+}
+
+func (m *workqueueMetrics) NewAddsMetric(name string) workqueue.CounterMetric {
+	return m.adds.WithLabelValues(name)
+}
+
+func (m *workqueueMetrics) NewLatencyMetric(name string) workqueue.SummaryMetric {
+	return microsecondsObserver{m.latency.WithLabelValues(name)}
+}
+
+func (m *workqueueMetrics) NewWorkDurationMetric(name string) workqueue.SummaryMetric {
+	return microsecondsObserver{m.workDuration.WithLabelValues(name)}
+}
+
+func (m *workqueueMetrics) NewRetriesMetric(name string) workqueue.CounterMetric {
+	return m.retries.WithLabelValues(name)
+}
+
+// microsecondsObserver observes in seconds the durations workqueue reports in microseconds
+type microsecondsObserver struct {
+	prometheus.Observer
+}
+
+func (o microsecondsObserver) Observe(microseconds float64) {
+	o.Observer.Observe(microseconds / 1e6)
+}
+
+//RegisterWorkqueueMetrics func, registers the prometheus metrics provider of workqueue,
+// queues named by InformerOpts.QueueName are collected with label name.
+// The provider of workqueue can be set only once, call it before creating the informers.
+func RegisterWorkqueueMetrics(registerer prometheus.Registerer) error {
+	m := newWorkqueueMetrics()
+	for _, c := range []prometheus.Collector{m.depth, m.adds, m.latency, m.workDuration, m.retries} {
+		if err := registerer.Register(c); err != nil {
+			return err
+		}
+	}
+	workqueue.SetProvider(m)
+	return nil
+}
+
+//MetricsHandler func
+func MetricsHandler(gatherer prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{})
+}
+
+// maxResyncCycleGap separates the resync cycles of a watch, as the cached objects are replayed at once on each cycle
+const maxResyncCycleGap = time.Minute
+
+// resynced counts the object replayed by resync in the current cycle, or starts a new cycle if no objects replayed within the gap.
+// It is called by the single event handler of the watch.
+func (w *informerWatch) resynced() {
+	gap := w.Resync / 2
+	if gap <= 0 || gap > maxResyncCycleGap {
+		gap = maxResyncCycleGap
+	}
+	now := time.Now()
+	if now.Sub(w.lastResync) > gap {
+		w.informer.metrics.resyncs.WithLabelValues(w.name).Inc()
+		w.informer.metrics.resyncObjects.WithLabelValues(w.name).Set(0)
+	}
+	w.lastResync = now
+	w.informer.metrics.resyncObjects.WithLabelValues(w.name).Inc()
+}
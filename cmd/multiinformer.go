@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/xiaopal/kube-informer/pkg/logging"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+)
+
+type clusterContextKey struct{}
+
+//ClusterFromContext func returns the cluster of the event passed to the handlers (and OnDropped) by MultiInformer
+func ClusterFromContext(ctx context.Context) string {
+	cluster, _ := ctx.Value(clusterContextKey{}).(string)
+	return cluster
+}
+
+type multiInformer struct {
+	clusters  []string
+	informers map[string]*informer
+	lock      sync.Mutex
+	watches   map[int]map[string]int
+	nextIndex int
+	events    chan Event
+	logger    logging.Logger
+}
+
+//NewMultiInformer func watches the same resources in each cluster of kubeConfigs (keyed by cluster name),
+//the cluster of events is passed to the handlers in ctx, see ClusterFromContext
+func NewMultiInformer(kubeConfigs map[string]*rest.Config, opts InformerOpts) Informer {
+	if opts.Logger == nil {
+		opts.Logger = logger
+	}
+	m := &multiInformer{
+		informers: map[string]*informer{},
+		watches:   map[int]map[string]int{},
+		logger:    opts.Logger,
+	}
+	for cluster, kubeConfig := range kubeConfigs {
+		clusterOpts, cluster := opts, cluster
+		if onWatchError := opts.OnWatchError; onWatchError != nil {
+			clusterOpts.OnWatchError = func(watch string, category WatchErrorCategory, err error) {
+				onWatchError(fmt.Sprintf("%s: %s", cluster, watch), category, err)
+			}
+		}
+		if onSynced := opts.OnSynced; onSynced != nil {
+			clusterOpts.OnSynced = func(watch string) {
+				onSynced(fmt.Sprintf("%s: %s", cluster, watch))
+			}
+		}
+		if opts.CheckpointFile != "" {
+			clusterOpts.CheckpointFile = opts.CheckpointFile + "." + cluster
+		}
+		if opts.QueueName != "" {
+			clusterOpts.QueueName = opts.QueueName + "." + cluster
+		}
+		if opts.Metrics != nil {
+			clusterOpts.Metrics = prometheus.WrapRegistererWith(prometheus.Labels{"cluster": cluster}, opts.Metrics)
+		}
+		m.clusters = append(m.clusters, cluster)
+		m.informers[cluster] = NewInformer(kubeConfig, clusterOpts).(*informer)
+		m.informers[cluster].cluster = cluster
+	}
+	sort.Strings(m.clusters)
+	return m
+}
+
+func (m *multiInformer) Watch(apiVersion string, kind string, namespace string, opts WatchOpts) error {
+	_, err := m.watchIndices(apiVersion, kind, namespace, opts)
+	return err
+}
+
+// watchIndices adds the watches like Watch, it returns the indices of the watches added even if some failed
+func (m *multiInformer) watchIndices(apiVersion string, kind string, namespace string, opts WatchOpts) ([]int, error) {
+	indices := []int{}
+	err := watchKinds(kind, func(kind string) error {
+		return watchNamespacesOnce(namespace, m.logger, func(namespace string) (string, error) {
+			index, clusterScoped, err := m.watchKind(apiVersion, kind, namespace, opts)
+			if index >= 0 {
+				indices = append(indices, index)
+			}
+			return clusterScoped, err
+		})
+	})
+	return indices, err
+}
+
+// watchKind adds the watch to each cluster without holding the lock while waiting for the caches to sync,
+// it returns the index of the watch (-1 if failed) and the name of the watch if the resource is cluster-scoped (see watchNamespacesOnce)
+func (m *multiInformer) watchKind(apiVersion string, kind string, namespace string, opts WatchOpts) (int, string, error) {
+	indices, clusterScoped := map[string]int{}, ""
+	for _, cluster := range m.clusters {
+		index, err := m.informers[cluster].addWatch(apiVersion, kind, namespace, opts)
+		if index >= 0 {
+			indices[cluster] = index
+			if name := m.informers[cluster].clusterScopedWatch(index, namespace); name != "" {
+				clusterScoped = name
+			}
+		}
+		if err != nil {
+			for cluster, index := range indices {
+				m.informers[cluster].StopWatch(index)
+			}
+			return -1, clusterScoped, fmt.Errorf("cluster %s: %v", cluster, err)
+		}
+	}
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	index := m.nextIndex
+	m.watches[index] = indices
+	m.nextIndex++
+	return index, clusterScoped, nil
+}
+
+func (m *multiInformer) StopWatch(index int) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	indices, ok := m.watches[index]
+	if !ok {
+		return fmt.Errorf("watch %d not found", index)
+	}
+	delete(m.watches, index)
+	for cluster, index := range indices {
+		if err := m.informers[cluster].StopWatch(index); err != nil {
+			return fmt.Errorf("cluster %s: %v", cluster, err)
+		}
+	}
+	return nil
+}
+
+func (m *multiInformer) UpdateSelector(index int, selector string) error {
+	// not locked while waiting for the new watches to sync
+	m.lock.Lock()
+	indices, ok := m.watches[index]
+	m.lock.Unlock()
+	if !ok {
+		return fmt.Errorf("watch %d not found", index)
+	}
+	for cluster, index := range indices {
+		if err := m.informers[cluster].UpdateSelector(index, selector); err != nil {
+			return fmt.Errorf("cluster %s: %v", cluster, err)
+		}
+	}
+	return nil
+}
+
+func (m *multiInformer) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make(chan error, len(m.clusters))
+	for _, cluster := range m.clusters {
+		go func(cluster string) {
+			// stop all clusters if any fails
+			defer cancel()
+			if err := m.informers[cluster].Run(ctx); err != nil {
+				errs <- fmt.Errorf("cluster %s: %v", cluster, err)
+				return
+			}
+			errs <- nil
+		}(cluster)
+	}
+	var err error
+	for range m.clusters {
+		if e := <-errs; e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (m *multiInformer) Stop() {
+	for _, informer := range m.informers {
+		informer.Stop()
+	}
+}
+
+// WatchStatus returns the status of the watches in each cluster
+func (m *multiInformer) WatchStatus() []WatchInfo {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	indices := make([]int, 0, len(m.watches))
+	for index := range m.watches {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	infos := []WatchInfo{}
+	for _, index := range indices {
+		for _, cluster := range m.clusters {
+			clusterIndex, ok := m.watches[index][cluster]
+			if !ok {
+				continue
+			}
+			for _, info := range m.informers[cluster].WatchStatus() {
+				if info.Index == clusterIndex {
+					info.Index = index
+					infos = append(infos, info)
+				}
+			}
+		}
+	}
+	return infos
+}
+
+// ByIndex returns the matched objects of all clusters
+func (m *multiInformer) ByIndex(index int, indexName string, indexValue string) ([]*unstructured.Unstructured, error) {
+	m.lock.Lock()
+	indices, ok := m.watches[index]
+	m.lock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("watch %d not found", index)
+	}
+	ret := []*unstructured.Unstructured{}
+	for _, cluster := range m.clusters {
+		objs, err := m.informers[cluster].ByIndex(indices[cluster], indexName, indexValue)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %v", cluster, err)
+		}
+		ret = append(ret, objs...)
+	}
+	return ret, nil
+}
+
+// Get returns the object of the first cluster having it in the order of cluster names, use ByIndex for all clusters
+func (m *multiInformer) Get(index int, namespace string, name string) (*unstructured.Unstructured, bool, error) {
+	m.lock.Lock()
+	indices, ok := m.watches[index]
+	m.lock.Unlock()
+	if !ok {
+		return nil, false, fmt.Errorf("watch %d not found", index)
+	}
+	for _, cluster := range m.clusters {
+		obj, exists, err := m.informers[cluster].Get(indices[cluster], namespace, name)
+		if err != nil {
+			return nil, false, fmt.Errorf("cluster %s: %v", cluster, err)
+		}
+		if exists {
+			return obj, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (m *multiInformer) Reprocess(index int, namespace string, name string) (bool, error) {
+	m.lock.Lock()
+	indices, ok := m.watches[index]
+	m.lock.Unlock()
+	if !ok {
+		return false, fmt.Errorf("watch %d not found", index)
+	}
+	found := false
+	for _, cluster := range m.clusters {
+		ok, err := m.informers[cluster].Reprocess(indices[cluster], namespace, name)
+		if err != nil {
+			return found, fmt.Errorf("cluster %s: %v", cluster, err)
+		}
+		found = found || ok
+	}
+	return found, nil
+}
+
+func (m *multiInformer) IsDescendantOf(obj *unstructured.Unstructured, owner schema.GroupVersionKind, name string) (bool, error) {
+	for _, cluster := range m.clusters {
+		// the uids of owners never match in other clusters
+		ok, err := m.informers[cluster].IsDescendantOf(obj, owner, name)
+		if err != nil {
+			return false, fmt.Errorf("cluster %s: %v", cluster, err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *multiInformer) Events() <-chan Event {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.events == nil {
+		m.events = make(chan Event)
+		forwarders := sync.WaitGroup{}
+		for _, informer := range m.informers {
+			forwarders.Add(1)
+			go func(events <-chan Event) {
+				defer forwarders.Done()
+				for event := range events {
+					m.events <- event
+				}
+			}(informer.Events())
+		}
+		go func() {
+			forwarders.Wait()
+			close(m.events)
+		}()
+	}
+	return m.events
+}
+
+func (m *multiInformer) HasSynced() bool {
+	for _, informer := range m.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *multiInformer) WaitForSync(ctx context.Context) error {
+	for _, cluster := range m.clusters {
+		if err := m.informers[cluster].WaitForSync(ctx); err != nil {
+			return fmt.Errorf("cluster %s: %v", cluster, err)
+		}
+	}
+	return nil
+}
+
+func (m *multiInformer) Healthy() bool {
+	for _, informer := range m.informers {
+		if !informer.Healthy() {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *multiInformer) Refresh() {
+	for _, informer := range m.informers {
+		informer.Refresh()
+	}
+}
+
+func (m *multiInformer) SetMaxRetries(maxRetries int) {
+	for _, informer := range m.informers {
+		informer.SetMaxRetries(maxRetries)
+	}
+}
+
+func (m *multiInformer) SetWorkers(workers int) error {
+	for _, cluster := range m.clusters {
+		if err := m.informers[cluster].SetWorkers(workers); err != nil {
+			return fmt.Errorf("cluster %s: %v", cluster, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/xiaopal/kube-informer/pkg/logging"
+)
+
+// newTestMultiInformer returns the multi informer of the clusters not run, logging to buf
+func newTestMultiInformer(t *testing.T, buf *bytes.Buffer, clusters ...string) *multiInformer {
+	m := &multiInformer{
+		informers: map[string]*informer{},
+		watches:   map[int]map[string]int{},
+		logger:    logging.NewTextLogger(buf, "test"),
+	}
+	for _, cluster := range clusters {
+		m.clusters = append(m.clusters, cluster)
+		m.informers[cluster] = NewInformerWithClients(newTestDynamicClient(t), newTestRESTMapper(), InformerOpts{Logger: m.logger}).(*informer)
+		m.informers[cluster].cluster = cluster
+	}
+	return m
+}
+
+func TestMultiInformerWatchClusterScopedOnce(t *testing.T) {
+	buf := &bytes.Buffer{}
+	m := newTestMultiInformer(t, buf, "a", "b")
+	if err := m.Watch("v1", "Namespace", "ns1,ns2", WatchOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Watch("v1", "ConfigMap", "ns1,ns2", WatchOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	// namespaces watched once, configmaps in each namespace
+	if len(m.watches) != 3 {
+		t.Errorf("%d watches added, expected 3", len(m.watches))
+	}
+	for _, cluster := range m.clusters {
+		if watches := len(m.informers[cluster].WatchStatus()); watches != 3 {
+			t.Errorf("%d watches in cluster %s, expected 3", watches, cluster)
+		}
+	}
+	if !strings.Contains(buf.String(), "namespaces ignored for cluster-scoped resource") {
+		t.Errorf("namespaces ignored not logged: %s", buf.String())
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func handleNATSEvent(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+	if !handlerEvents[event] {
+		return nil
+	}
+	cluster := ClusterFromContext(ctx)
+	subject := &bytes.Buffer{}
+	if err := natsSubjectTemplate.Execute(subject, map[string]interface{}{
+		"cluster": cluster,
+		"event":   string(event),
+		"object":  obj.Object,
+	}); err != nil {
+		return PermanentError(fmt.Errorf("failed to render nats subject: %v", err))
+	}
+	data, err := json.Marshal(&webhookEvent{
+		Cluster:         cluster,
+		Event:           event,
+		Object:          obj,
+		OldObject:       old,
+		Retries:         numRetries,
+		Synced:          synced,
+		LastAppliedDiff: LastAppliedDiffFromContext(ctx),
+	})
+	if err != nil {
+		return PermanentError(fmt.Errorf("failed to marshal event: %v", err))
+	}
+	return natsClient.Publish(ctx, subject.String(), data)
+}
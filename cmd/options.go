@@ -3,73 +3,365 @@ package main
 import (
 	"flag"
 	"fmt"
-	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/template"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
 
 	"time"
 
+	"github.com/xiaopal/kube-informer/pkg/filesink"
+	"github.com/xiaopal/kube-informer/pkg/kafka"
 	"github.com/xiaopal/kube-informer/pkg/kubeclient"
 	"github.com/xiaopal/kube-informer/pkg/leaderelect"
+	"github.com/xiaopal/kube-informer/pkg/logging"
+	"github.com/xiaopal/kube-informer/pkg/nats"
+	"github.com/xiaopal/kube-informer/pkg/redis"
+	"github.com/xiaopal/kube-informer/pkg/webhook"
 
-	"golang.org/x/time/rate"
-	"k8s.io/client-go/util/workqueue"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 var (
-	logger                  *log.Logger
+	logger                  logging.Logger
+	logFormat               string
 	watches                 []string
-	parsedWatches           []map[string]string
+	parsedWatches           []watchConfig
+	defaultWatch            watchConfig
+	crdGroups               []string
+	configFile              string
+	configReloadInterval    time.Duration
+	loadedConfig            *informerConfig
+	configModTime           time.Time
+	configOverrides         map[string]bool
 	selector                string
+	fieldSelector           string
+	excludeNamespaces       []string
+	listChunkSize           int64
+	dropManagedFields       bool
+	protobuf                bool
+	dropFields              []string
+	ownerFilter             string
+	transitionPath          string
+	transitionValue         string
+	annotationMatch         []string
+	annotationExclude       []string
+	generationChangesOnly   bool
+	statusChangesOnly       bool
+	diffLastApplied         bool
+	clusterContexts         []string
+	eventFilter             string
 	resyncDuration          time.Duration
+	watchTimeout            time.Duration
 	events                  []string
 	handlerEvents           map[EventType]bool
+	handlerType             string
 	handlerCommand          []string
 	handlerName             string
 	handlerPassStdin        bool
+	handlerPassStdinEvent   bool
 	handlerPassEnv          bool
 	handlerPassArgs         bool
+	handlerArgs             []string
+	handlerArgTemplates     []*template.Template
+	handlerTimeout          time.Duration
+	fieldManager            string
+	outputTemplate          string
 	handlerMaxRetries       int
+	retryPolicy             string
+	accessCheck             string
+	handlerWorkers          int
+	handlerDrain            bool
+	handlerReadOnly         bool
+	handlerEventRate        float64
+	handlerEventBurst       int
+	droppedFile             string
+	checkpointFile          string
+	checkpointInterval      time.Duration
+	syncTimeout             time.Duration
+	partialSync             bool
+	skipOlderThan           time.Duration
+	tombstoneTTL            time.Duration
+	dryRun                  bool
+	maxObjectBytes          int
+	maxQueueLength          int
+	noCoalesce              bool
+	discoveryRefresh        time.Duration
+	queuePolicy             string
+	handlerDebounce         time.Duration
+	reconcileInterval       time.Duration
+	handlerDrainTimeout     time.Duration
 	handlerRetriesBaseDelay time.Duration
 	handlerRetriesMaxDelay  time.Duration
+	handlerRetriesJitter    float64
+	handlerBackoff          string
 	kubeClient              kubeclient.Client
 	leaderHelper            leaderelect.Helper
+	webhookClient           webhook.Client
+	kafkaClient             kafka.Client
+	kafkaKey                string
+	kafkaKeyTemplate        *template.Template
+	natsClient              nats.Client
+	fileClient              filesink.Client
+	natsSubject             string
+	natsSubjectTemplate     *template.Template
+	redisClient             redis.Client
+	redisStream             string
+	redisStreamTemplate     *template.Template
+	listenAddr              string
+	adminAPI                bool
+	metricsRegistry         *prometheus.Registry
 	initialized             bool
 )
 
 func parseWatch(watch string) map[string]string {
-	opts := map[string]string{}
+	opts, last := map[string]string{}, ""
 	for _, s := range strings.Split(watch, ",") {
 		if opt := strings.SplitN(s, "=", 2); len(opt) == 2 {
-			opts[strings.TrimSpace(opt[0])] = strings.TrimSpace(opt[1])
+			last = strings.TrimSpace(opt[0])
+			opts[last] = strings.TrimSpace(opt[1])
+		} else if last != "" && strings.TrimSpace(s) != "" {
+			// list values, eg. `kind=Deployment,ReplicaSet`
+			opts[last] += "," + strings.TrimSpace(s)
 		}
 	}
 	return opts
 }
 
+// parseAnnotations parses `key=value` or `key` (for any value) to map
+func parseAnnotations(annotations []string) map[string]string {
+	if len(annotations) == 0 {
+		return nil
+	}
+	ret := map[string]string{}
+	for _, annotation := range annotations {
+		kv := strings.SplitN(annotation, "=", 2)
+		key, value := strings.TrimSpace(kv[0]), ""
+		if len(kv) == 2 {
+			value = strings.TrimSpace(kv[1])
+		}
+		ret[key] = value
+	}
+	return ret
+}
+
 func initOptions(cmd *cobra.Command, args []string) (err error) {
+	if logger, err = logging.New(logFormat, os.Stderr, "kube-informer"); err != nil {
+		return err
+	}
+	if err := kubeClient.Validate(); err != nil {
+		return err
+	}
 	handlerCommand = args
-	if len(handlerCommand) < 1 {
-		return fmt.Errorf("handlerCommand required")
+	switch handlerType {
+	case "exec":
+		if len(handlerCommand) < 1 && !dryRun {
+			return fmt.Errorf("handlerCommand required")
+		}
+		if handlerName == "" && len(handlerCommand) > 0 {
+			handlerName = filepath.Base(handlerCommand[0])
+		}
+		handlerArgTemplates = []*template.Template{}
+		for _, arg := range handlerArgs {
+			t, err := template.New("arg").Parse(arg)
+			if err != nil {
+				return fmt.Errorf("failed to parse --arg %q: %v", arg, err)
+			}
+			handlerArgTemplates = append(handlerArgTemplates, t)
+		}
+	case "webhook":
+		if err := webhookClient.Validate(); err != nil {
+			return err
+		}
+	case "log":
+		if outputTemplate == "" {
+			outputTemplate = "{.metadata.namespace}/{.metadata.name}"
+		}
+	case "nats":
+		if err := natsClient.Validate(); err != nil {
+			return err
+		}
+		if natsSubjectTemplate, err = template.New("subject").Parse(natsSubject); err != nil {
+			return fmt.Errorf("failed to parse --nats-subject %q: %v", natsSubject, err)
+		}
+	case "redis":
+		if err := redisClient.Validate(); err != nil {
+			return err
+		}
+		if redisStreamTemplate, err = template.New("stream").Parse(redisStream); err != nil {
+			return fmt.Errorf("failed to parse --redis-stream %q: %v", redisStream, err)
+		}
+	case "file":
+		if err := fileClient.Validate(); err != nil {
+			return err
+		}
+	case "kafka":
+		if err := kafkaClient.Validate(); err != nil {
+			return err
+		}
+		if kafkaKey != "" {
+			if kafkaKeyTemplate, err = template.New("key").Parse(kafkaKey); err != nil {
+				return fmt.Errorf("failed to parse --kafka-key %q: %v", kafkaKey, err)
+			}
+		}
+	default:
+		return fmt.Errorf("unknown handler %q", handlerType)
 	}
-	if handlerName == "" {
-		handlerName = filepath.Base(handlerCommand[0])
+	if outputTemplate != "" {
+		if _, err := parseOutput(outputTemplate); err != nil {
+			return err
+		}
 	}
 
-	parsedWatches = []map[string]string{}
+	var owner *OwnerFilter
+	if ownerFilter != "" {
+		opts := parseWatch(ownerFilter)
+		owner = &OwnerFilter{
+			APIVersion: opts["apiVersion"],
+			Kind:       opts["kind"],
+			Name:       opts["name"],
+			UID:        types.UID(opts["uid"]),
+			Controller: opts["controller"] == "true",
+		}
+	}
+	if transitionValue != "" && transitionPath == "" {
+		return fmt.Errorf("--transition-value requires --transition-path")
+	}
+	var transition *TransitionFilter
+	if transitionPath != "" {
+		transition = &TransitionFilter{Path: transitionPath, Value: transitionValue}
+	}
+	defaultWatch = watchConfig{
+		LabelSelector:         selector,
+		FieldSelector:         fieldSelector,
+		Resync:                metav1.Duration{Duration: resyncDuration},
+		WatchTimeout:          metav1.Duration{Duration: watchTimeout},
+		ExcludeNamespaces:     excludeNamespaces,
+		ListChunkSize:         listChunkSize,
+		Owner:                 owner,
+		Transition:            transition,
+		AnnotationMatch:       parseAnnotations(annotationMatch),
+		AnnotationExclude:     parseAnnotations(annotationExclude),
+		GenerationChangesOnly: generationChangesOnly,
+		StatusChangesOnly:     statusChangesOnly,
+		DiffLastApplied:       diffLastApplied,
+	}
+	parsedWatches = []watchConfig{}
 	for _, line := range watches {
 		for _, watch := range strings.Split(line, ":") {
 			if strings.TrimSpace(watch) != "" {
-				parsedWatches = append(parsedWatches, parseWatch(watch))
+				opts := parseWatch(watch)
+				maxConcurrent := 0
+				if opts["maxConcurrent"] != "" {
+					if maxConcurrent, err = strconv.Atoi(opts["maxConcurrent"]); err != nil {
+						return fmt.Errorf("failed to parse maxConcurrent of --watch %q: %v", watch, err)
+					}
+				}
+				parsed := defaultWatch
+				parsed.APIVersion, parsed.Kind, parsed.Namespace, parsed.Name = opts["apiVersion"], opts["kind"], opts["namespace"], opts["name"]
+				parsed.MaxConcurrent = maxConcurrent
+				if opts["watchTimeout"] != "" {
+					if parsed.WatchTimeout.Duration, err = time.ParseDuration(opts["watchTimeout"]); err != nil {
+						return fmt.Errorf("failed to parse watchTimeout of --watch %q: %v", watch, err)
+					}
+				}
+				parsedWatches = append(parsedWatches, parsed)
 			}
 		}
 	}
-	if len(parsedWatches) < 1 {
-		return fmt.Errorf("--watch required")
+	loadedConfig = nil
+	if configFile != "" {
+		stat, err := os.Stat(configFile)
+		if err != nil {
+			return fmt.Errorf("failed to read config: %v", err)
+		}
+		config, err := loadConfig(configFile)
+		if err != nil {
+			return err
+		}
+		if err := config.validate(); err != nil {
+			return fmt.Errorf("invalid config %s: %v", configFile, err)
+		}
+		// the flags set override the config file, also when reloaded
+		configOverrides = map[string]bool{}
+		for _, name := range []string{"max-retries", "workers"} {
+			configOverrides[name] = cmd.Flags().Changed(name)
+		}
+		if config.MaxRetries != nil && !cmd.Flags().Changed("max-retries") {
+			handlerMaxRetries = *config.MaxRetries
+		}
+		if config.Workers != nil && !cmd.Flags().Changed("workers") {
+			handlerWorkers = *config.Workers
+		}
+		if config.SyncTimeout != nil && !cmd.Flags().Changed("sync-timeout") {
+			syncTimeout = config.SyncTimeout.Duration
+		}
+		if config.PartialSync != nil && !cmd.Flags().Changed("partial-sync") {
+			partialSync = *config.PartialSync
+		}
+		loadedConfig, configModTime = config, stat.ModTime()
+	}
+	if len(parsedWatches) < 1 && (loadedConfig == nil || len(loadedConfig.Watches) < 1) && len(crdGroups) < 1 {
+		return fmt.Errorf("--watch, --config or --crd-group required")
+	}
+	if len(crdGroups) > 0 && len(clusterContexts) > 0 {
+		return fmt.Errorf("--crd-group is not supported with --cluster-context")
+	}
+	for _, pattern := range crdGroups {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --crd-group %q: %v", pattern, err)
+		}
+	}
+	if err := (&informerConfig{Watches: parsedWatches}).validate(); err != nil {
+		return err
+	}
+	if configReloadInterval < 0 {
+		return fmt.Errorf("--config-reload-interval must not be negative")
+	}
+	if configReloadInterval > 0 && configFile == "" {
+		return fmt.Errorf("--config-reload-interval requires --config")
+	}
+
+	if eventFilter != "" {
+		if _, err := CompileFilter(eventFilter); err != nil {
+			return err
+		}
+	}
+
+	if handlerBackoff != "" {
+		if _, err := ParseRateLimiter(handlerBackoff); err != nil {
+			return err
+		}
+	}
+	if noCoalesce && (handlerDebounce > 0 || reconcileInterval > 0) {
+		return fmt.Errorf("--no-coalesce conflicts with --debounce and --reconcile-interval")
+	}
+	if maxQueueLength < 0 {
+		return fmt.Errorf("--max-queue-length must not be negative")
+	}
+	if p := QueuePolicy(queuePolicy); p != QueueBlock && p != QueueDropOldest && p != QueueDropNewest {
+		return fmt.Errorf("--queue-policy must be %s, %s or %s", QueueBlock, QueueDropOldest, QueueDropNewest)
+	}
+	if c := AccessCheck(accessCheck); c != AccessCheckFail && c != AccessCheckWarn && c != AccessCheckSkip {
+		return fmt.Errorf("--access-check must be %s, %s or %s", AccessCheckFail, AccessCheckWarn, AccessCheckSkip)
+	}
+	if RetryPolicy(retryPolicy) != AtLeastOnce && RetryPolicy(retryPolicy) != AtMostOnce {
+		return fmt.Errorf("--retry-policy must be %s or %s", AtLeastOnce, AtMostOnce)
+	}
+	if adminAPI && listenAddr == "" {
+		return fmt.Errorf("--admin-api requires --listen")
+	}
+	if partialSync && syncTimeout <= 0 {
+		return fmt.Errorf("--partial-sync requires --sync-timeout")
+	}
+	if handlerRetriesJitter < 0 {
+		return fmt.Errorf("--retries-jitter must not be negative")
 	}
 
 	handlerEvents = map[EventType]bool{}
@@ -80,12 +372,11 @@ func initOptions(cmd *cobra.Command, args []string) (err error) {
 	return nil
 }
 
-func handlerRateLimiter() workqueue.RateLimiter {
-	return workqueue.NewMaxOfRateLimiter(
-		workqueue.NewItemExponentialFailureRateLimiter(5*time.Millisecond, 1000*time.Second),
-		// 10 qps, 100 bucket size.  This is only for retry speed and its only the overall factor (not per item)
-		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
-	)
+func envOrDefault(key string, d string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return d
 }
 
 func envToInt(key string, d int) int {
@@ -97,6 +388,15 @@ func envToInt(key string, d int) int {
 	return d
 }
 
+func envToFloat(key string, d float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if ret, err := strconv.ParseFloat(v, 64); err == nil {
+			return ret
+		}
+	}
+	return d
+}
+
 func envToDuration(key string, d time.Duration) time.Duration {
 	if v := os.Getenv(key); v != "" {
 		if ret, err := time.ParseDuration(v); err == nil {
@@ -107,20 +407,32 @@ func envToDuration(key string, d time.Duration) time.Duration {
 }
 
 func init() {
-	logger = log.New(os.Stderr, "[kube-informer] ", log.Flags())
+	logger = logging.NewTextLogger(os.Stderr, "kube-informer")
+}
+
+// parseOptions parses the flags and args (defaulting to INFORMER_OPTS_* env), exits unless the informer is to run
+func parseOptions() {
 	cmd := &cobra.Command{
-		Use:     fmt.Sprintf("%s [flags] handlerCommand args...", os.Args[0]),
+		Use:     fmt.Sprintf("%s [flags] [handlerCommand args...]", os.Args[0]),
 		PreRunE: initOptions,
 		Run: func(cmd *cobra.Command, args []string) {
 			initialized = true
 		},
 	}
 
-	events = []string{string(EventAdd), string(EventUpdate), string(EventDelete)}
+	events = []string{string(EventAdd), string(EventUpdate), string(EventDelete), string(EventResync), string(EventReconcile)}
 	if envEvents := os.Getenv("INFORMER_OPTS_EVENT"); envEvents != "" {
 		events = strings.Fields(envEvents)
 	}
 
+	excludeNamespaces = strings.Fields(os.Getenv("INFORMER_OPTS_EXCLUDE_NAMESPACE"))
+	clusterContexts = strings.Fields(os.Getenv("INFORMER_OPTS_CLUSTER_CONTEXT"))
+	crdGroups = strings.Fields(os.Getenv("INFORMER_OPTS_CRD_GROUP"))
+	dropFields = strings.Fields(os.Getenv("INFORMER_OPTS_DROP_FIELD"))
+	annotationMatch = strings.Fields(os.Getenv("INFORMER_OPTS_ANNOTATION"))
+	annotationExclude = strings.Fields(os.Getenv("INFORMER_OPTS_EXCLUDE_ANNOTATION"))
+	handlerArgs = strings.Fields(os.Getenv("INFORMER_OPTS_ARG"))
+
 	watches = []string{}
 	if envWatch := os.Getenv("INFORMER_OPTS_WATCH"); envWatch != "" {
 		watches = strings.Split(envWatch, ":")
@@ -138,20 +450,93 @@ func init() {
 	})
 	leaderHelper.BindFlags(flags, "INFORMER_OPTS_")
 
+	webhookClient = webhook.NewClient(&webhook.ClientOpts{})
+	webhookClient.BindFlags(flags, "INFORMER_OPTS_")
+
+	kafkaClient = kafka.NewClient(&kafka.ClientOpts{})
+	kafkaClient.BindFlags(flags, "INFORMER_OPTS_")
+
+	natsClient = nats.NewClient(&nats.ClientOpts{})
+	natsClient.BindFlags(flags, "INFORMER_OPTS_")
+
+	redisClient = redis.NewClient(&redis.ClientOpts{})
+	redisClient.BindFlags(flags, "INFORMER_OPTS_")
+
+	fileClient = filesink.NewClient(&filesink.ClientOpts{})
+	fileClient.BindFlags(flags, "INFORMER_OPTS_")
+
 	flags.StringArrayVarP(&watches, "watch", "w", watches, "watch resources, eg. `apiVersion=v1,kind=ConfigMap`")
+	flags.StringArrayVar(&clusterContexts, "cluster-context", clusterContexts, "watch in each cluster of the kubeconfig contexts instead of the current context")
+	flags.StringVarP(&configFile, "config", "c", os.Getenv("INFORMER_OPTS_CONFIG"), "config file (yaml or json) declaring watches")
+	flags.DurationVar(&configReloadInterval, "config-reload-interval", envToDuration("INFORMER_OPTS_CONFIG_RELOAD_INTERVAL", 0), "check the config file for changes every interval and apply the changed watches without restart, 0 to disable")
 	flags.StringVarP(&selector, "selector", "l", os.Getenv("INFORMER_OPTS_SELECTOR"), "selector (label query) to filter on")
+	flags.StringVar(&fieldSelector, "field-selector", os.Getenv("INFORMER_OPTS_FIELD_SELECTOR"), "selector (field query) to filter on, eg. `status.phase=Running`")
+	flags.StringSliceVar(&excludeNamespaces, "exclude-namespace", excludeNamespaces, "skip events of objects in these namespaces")
+	flags.StringSliceVar(&crdGroups, "crd-group", crdGroups, "watch the custom resources of the CRDs in the groups matching the patterns, eg. `*.example.com`, added and stopped as the CRDs are created and deleted")
+	flags.StringVar(&eventFilter, "filter", os.Getenv("INFORMER_OPTS_FILTER"), "skip events unless the (CEL) expression over event, object and oldObject returns true, requires building with -tags cel")
+	flags.Int64Var(&listChunkSize, "list-chunk-size", int64(envToInt("INFORMER_OPTS_LIST_CHUNK_SIZE", 0)), "list objects in chunks of the size from etcd instead of at once from apiserver cache, 0 to disable")
+	flags.BoolVar(&protobuf, "protobuf", os.Getenv("INFORMER_OPTS_PROTOBUF") != "", "list and watch built-in kinds with protobuf instead of json to reduce CPU and memory, other kinds (eg. CRDs) use json")
+	flags.BoolVar(&dropManagedFields, "drop-managed-fields", os.Getenv("INFORMER_OPTS_DROP_MANAGED_FIELDS") != "", "drop metadata.managedFields of objects to save memory")
+	flags.StringSliceVar(&dropFields, "drop-field", dropFields, "drop fields of objects to save memory, eg. `status`")
+	flags.StringArrayVar(&annotationMatch, "annotation", annotationMatch, "only handle objects with the annotation, eg. `key=value` or `key` for any value")
+	flags.StringArrayVar(&annotationExclude, "exclude-annotation", annotationExclude, "skip objects with the annotation, eg. `key=value` or `key` for any value")
+	flags.BoolVar(&diffLastApplied, "diff-last-applied", os.Getenv("INFORMER_OPTS_DIFF_LAST_APPLIED") != "", "pass the diff of the kubectl last-applied-configuration annotation on update events, as env INFORMER_LAST_APPLIED_DIFF (and INFORMER_LAST_APPLIED_CHANGED) to the exec handler or lastAppliedDiff to the webhook")
+	flags.BoolVar(&generationChangesOnly, "generation-changes-only", os.Getenv("INFORMER_OPTS_GENERATION_CHANGES_ONLY") != "", "skip update events unless metadata.generation changed, eg. status only updates")
+	flags.BoolVar(&statusChangesOnly, "status-changes-only", os.Getenv("INFORMER_OPTS_STATUS_CHANGES_ONLY") != "", "skip update events unless status changed, eg. spec or metadata only updates")
+	flags.StringVar(&ownerFilter, "owner", os.Getenv("INFORMER_OPTS_OWNER"), "only handle objects owned directly by the owner, eg. `kind=ReplicaSet,name=example,controller=true`")
+	flags.StringVar(&transitionPath, "transition-path", os.Getenv("INFORMER_OPTS_TRANSITION_PATH"), "only handle objects transitioning into --transition-value at the jsonpath, eg. `.status.phase`")
+	flags.StringVar(&transitionValue, "transition-value", os.Getenv("INFORMER_OPTS_TRANSITION_VALUE"), "the value of --transition-path, eg. `Succeeded`")
 	flags.DurationVar(&resyncDuration, "resync", envToDuration("INFORMER_OPTS_RESYNC", 0), "resync period")
-	flags.StringSliceVarP(&events, "event", "e", events, "handle events")
+	flags.DurationVar(&watchTimeout, "watch-timeout", envToDuration("INFORMER_OPTS_WATCH_TIMEOUT", 0), "close the watches after the timeout and relist from apiserver (unlike --resync replaying the cache), 0 for the client-go default of 5-10m rewatching without relist")
+	flags.StringSliceVarP(&events, "event", "e", events, "handle events of add, update, delete, resync (the object is unchanged on periodic resync, handled as update before, add resync to an explicit list to keep handling them) and reconcile (with --reconcile-interval)")
+	flags.StringVar(&handlerType, "handler", envOrDefault("INFORMER_OPTS_HANDLER", "exec"), "handler, `exec` handlerCommand, print events by --output to stdout with `log`, post events to `webhook` --url, publish to `kafka` --kafka-topic or `nats` --nats-subject, add to `redis` --redis-stream, append to `file` --file-path")
+	flags.StringVar(&natsSubject, "nats-subject", envOrDefault("INFORMER_OPTS_NATS_SUBJECT", "k8s.{{.object.kind}}.{{.event}}"), "nats subject rendered by the go template over event, object and cluster")
+	flags.StringVar(&redisStream, "redis-stream", envOrDefault("INFORMER_OPTS_REDIS_STREAM", "kube-informer"), "redis stream key rendered by the go template over event, object and cluster")
+	flags.StringVar(&kafkaKey, "kafka-key", envOrDefault("INFORMER_OPTS_KAFKA_KEY", "{{.metadata.namespace}}/{{.metadata.name}}"), "kafka message key rendered by the go template over obj, empty for no key")
+	flags.StringVar(&outputTemplate, "output", os.Getenv("INFORMER_OPTS_OUTPUT"), "jsonpath template of objects printed by the `log` handler (default `{.metadata.namespace}/{.metadata.name}`) or passed to exec handler env INFORMER_OUTPUT, missing fields are empty")
 	flags.StringVar(&handlerName, "name", os.Getenv("INFORMER_OPTS_NAME"), "handler name")
 	flags.BoolVar(&handlerPassStdin, "pass-stdin", os.Getenv("INFORMER_OPTS_PASS_STDIN") != "", "pass obj json to handler stdin")
-	flags.BoolVar(&handlerPassEnv, "pass-env", os.Getenv("INFORMER_OPTS_PASS_ENV") != "", "pass obj json to handler env INFORMER_OBJECT")
+	flags.BoolVar(&handlerPassStdinEvent, "pass-stdin-event", os.Getenv("INFORMER_OPTS_PASS_STDIN_EVENT") != "", "pass the event json to handler stdin as posted by the webhook handler, with oldObject on update events, instead of the obj json of --pass-stdin")
+	flags.BoolVar(&handlerPassEnv, "pass-env", os.Getenv("INFORMER_OPTS_PASS_ENV") != "", "pass obj json to handler env INFORMER_OBJECT (and INFORMER_OLD_OBJECT on update)")
 	flags.BoolVar(&handlerPassArgs, "pass-args", os.Getenv("INFORMER_OPTS_PASS_ARGS") != "", "pass event and obj json to handler arg")
+	flags.StringArrayVar(&handlerArgs, "arg", handlerArgs, "append handler arg rendered by the go template over obj, eg. `{{.metadata.name}}`")
+	flags.StringVar(&fieldManager, "field-manager", envOrDefault("INFORMER_OPTS_FIELD_MANAGER", "kube-informer"), "field manager passed to the exec handler as env INFORMER_FIELD_MANAGER, eg. for `kubectl apply --server-side --field-manager`")
+	flags.DurationVar(&handlerTimeout, "timeout", envToDuration("INFORMER_OPTS_TIMEOUT", 0), "kill the exec handler (or cancel the other handlers) and retry if not done within the duration, 0 for no timeout")
 	flags.IntVar(&handlerMaxRetries, "max-retries", envToInt("INFORMER_OPTS_MAX_RETRIES", 15), "handler max retries, -1 for unlimited")
+	flags.StringVar(&retryPolicy, "retry-policy", envOrDefault("INFORMER_OPTS_RETRY_POLICY", string(AtLeastOnce)), "at-least-once to retry failed events, or at-most-once to drop them without retry")
+	flags.IntVar(&handlerWorkers, "workers", envToInt("INFORMER_OPTS_WORKERS", 1), "handler workers, the events of the same object are handled one at a time in order")
+	flags.DurationVar(&reconcileInterval, "reconcile-interval", envToDuration("INFORMER_OPTS_RECONCILE_INTERVAL", 0), "handle the objects added, updated or resynced as reconcile events at most once per interval with the latest state (delete events are handled immediately), 0 to disable, overrides --debounce")
+	flags.DurationVar(&handlerDebounce, "debounce", envToDuration("INFORMER_OPTS_DEBOUNCE", 0), "handle events of an object once it is not touched for the duration, 0 to disable")
+	flags.Float64Var(&handlerEventRate, "event-rate", envToFloat("INFORMER_OPTS_EVENT_RATE", 0), "max events handled per second, 0 for unlimited")
+	flags.DurationVar(&discoveryRefresh, "discovery-refresh-interval", envToDuration("INFORMER_OPTS_DISCOVERY_REFRESH_INTERVAL", 0), "reset the cached discovery of the api resources every interval, so that resources installed later (eg. CRDs) can be watched, 0 to disable")
+	flags.BoolVar(&noCoalesce, "no-coalesce", os.Getenv("INFORMER_OPTS_NO_COALESCE") != "", "handle every event in order with the object at the event instead of coalescing the events queued of the same object, eg. for audit (memory grows with the events queued)")
+	flags.IntVar(&maxQueueLength, "max-queue-length", envToInt("INFORMER_OPTS_MAX_QUEUE_LENGTH", 0), "limit the events waiting in the queue by --queue-policy, 0 for unlimited")
+	flags.StringVar(&queuePolicy, "queue-policy", envOrDefault("INFORMER_OPTS_QUEUE_POLICY", string(QueueBlock)), "block the watches until the queue is below --max-queue-length, or drop-oldest or drop-newest event (best-effort, dropped events are never handled)")
+	flags.IntVar(&handlerEventBurst, "event-burst", envToInt("INFORMER_OPTS_EVENT_BURST", 1), "max events handled at once within --event-rate")
+	flags.BoolVar(&handlerReadOnly, "handler-read-only", os.Getenv("INFORMER_OPTS_HANDLER_READ_ONLY") != "", "pass the cached objects to the handler without copying to reduce allocations at high event rates (the built-in handlers only read the objects)")
+	flags.BoolVar(&handlerDrain, "drain", os.Getenv("INFORMER_OPTS_DRAIN") != "", "handle queued events before exiting")
+	flags.DurationVar(&handlerDrainTimeout, "drain-timeout", envToDuration("INFORMER_OPTS_DRAIN_TIMEOUT", 0), "max time to handle queued events before exiting, 0 for no timeout")
+	flags.StringVar(&droppedFile, "dropped-file", os.Getenv("INFORMER_OPTS_DROPPED_FILE"), "append events dropped after max retries to the file as json lines, with the retries, the time first seen and the time elapsed retrying")
+	flags.IntVar(&maxObjectBytes, "max-object-bytes", envToInt("INFORMER_OPTS_MAX_OBJECT_BYTES", 0), "skip the events of objects larger than the size (estimated json bytes), 0 for unlimited")
+	flags.BoolVar(&dryRun, "dry-run", os.Getenv("INFORMER_OPTS_DRY_RUN") != "", "log the events instead of calling the handler, to check the watches and the volume of events")
+	flags.DurationVar(&tombstoneTTL, "tombstone-ttl", envToDuration("INFORMER_OPTS_TOMBSTONE_TTL", 0), "evict the last known states of deleted objects not handled within the duration, 0 to disable")
+	flags.DurationVar(&skipOlderThan, "skip-older-than", envToDuration("INFORMER_OPTS_SKIP_OLDER_THAN", 0), "skip the objects created longer than the duration ago on the initial list, 0 to disable")
+	flags.DurationVar(&syncTimeout, "sync-timeout", envToDuration("INFORMER_OPTS_SYNC_TIMEOUT", 0), "exit unless the caches of all watches are synced within the duration, 0 for no timeout")
+	flags.BoolVar(&partialSync, "partial-sync", os.Getenv("INFORMER_OPTS_PARTIAL_SYNC") != "", "handle the watches synced within --sync-timeout instead of exiting, the others keep retrying")
+	flags.StringVar(&accessCheck, "access-check", envOrDefault("INFORMER_OPTS_ACCESS_CHECK", string(AccessCheckWarn)), "check the permissions to list and watch of each watch at startup by SelfSubjectAccessReview, warn or fail if missing, or skip")
+	flags.StringVar(&checkpointFile, "checkpoint-file", os.Getenv("INFORMER_OPTS_CHECKPOINT_FILE"), "record the resourceVersions of handled objects to the file, to skip unchanged objects after restarts")
+	flags.DurationVar(&checkpointInterval, "checkpoint-interval", envToDuration("INFORMER_OPTS_CHECKPOINT_INTERVAL", 10*time.Second), "save the checkpoint file every interval (and on exit)")
 	flags.DurationVar(&handlerRetriesBaseDelay, "retries-base-delay", envToDuration("INFORMER_OPTS_RETRIES_BASE_DELAY", 5*time.Millisecond), "handler retries: base delay")
 	flags.DurationVar(&handlerRetriesMaxDelay, "retries-max-delay", envToDuration("INFORMER_OPTS_RETRIES_MAX_DELAY", 1000*time.Second), "handler retries: max delay")
+	flags.Float64Var(&handlerRetriesJitter, "retries-jitter", envToFloat("INFORMER_OPTS_RETRIES_JITTER", 0), "handler retries: add up to the fraction (eg. 0.1) of the delays randomly")
+	flags.StringVar(&handlerBackoff, "backoff", os.Getenv("INFORMER_OPTS_BACKOFF"), "handler retries: backoff policy, `default` or `exponential:<base delay>:<max delay>`, overrides --retries-*-delay")
+	flags.StringVar(&logFormat, "log-format", envOrDefault("INFORMER_OPTS_LOG_FORMAT", "text"), "log format, `text` or `json`")
+	flags.StringVar(&listenAddr, "listen", os.Getenv("INFORMER_OPTS_LISTEN"), "http listen address to serve /metrics, /readyz, /healthz and /watches, eg. `:8080`")
+	flags.BoolVar(&adminAPI, "admin-api", os.Getenv("INFORMER_OPTS_ADMIN_API") != "", "serve `POST /reprocess?watch=<index>&namespace=<namespace>&name=<name>` on --listen to handle the cached object again")
 
 	if err := cmd.Execute(); err != nil {
-		logger.Fatal(err)
+		logger.Error("failed to parse options", err)
+		os.Exit(1)
 	}
 	if !initialized {
 		os.Exit(0)
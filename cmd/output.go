@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// parseOutput returns the jsonpath of the template, missing fields are rendered empty
+func parseOutput(template string) (*jsonpath.JSONPath, error) {
+	j := jsonpath.New("output").AllowMissingKeys(true)
+	if err := j.Parse(template); err != nil {
+		return nil, fmt.Errorf("failed to parse --output %q: %v", template, err)
+	}
+	return j, nil
+}
+
+// renderOutput renders the object by --output, the jsonpath is parsed for each object as it is not safe for concurrent use
+func renderOutput(obj *unstructured.Unstructured) (string, error) {
+	j, err := parseOutput(outputTemplate)
+	if err != nil {
+		return "", err
+	}
+	buf := &bytes.Buffer{}
+	if err := j.Execute(buf, obj.Object); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func handleLogEvent(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+	if !handlerEvents[event] {
+		return nil
+	}
+	output, err := renderOutput(obj)
+	if err != nil {
+		return PermanentError(fmt.Errorf("failed to render output: %v", err))
+	}
+	fields := []string{string(event), output}
+	if cluster := ClusterFromContext(ctx); cluster != "" {
+		fields = append([]string{cluster}, fields...)
+	}
+	_, err = fmt.Fprintln(os.Stdout, strings.Join(fields, " "))
+	return err
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+//OwnerFilter type matches objects owned directly by the owner, empty fields match any owner.
+//Objects owned transitively (eg. pods of the replicasets of a deployment) are not matched.
+type OwnerFilter struct {
+	APIVersion string    `json:"apiVersion,omitempty"`
+	Kind       string    `json:"kind,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	UID        types.UID `json:"uid,omitempty"`
+	// Controller only matches the owner with `controller: true`
+	Controller bool `json:"controller,omitempty"`
+}
+
+//Match func
+func (f *OwnerFilter) Match(obj *unstructured.Unstructured) bool {
+	for _, owner := range obj.GetOwnerReferences() {
+		if f.Controller && (owner.Controller == nil || !*owner.Controller) {
+			continue
+		}
+		if (f.APIVersion == "" || f.APIVersion == owner.APIVersion) &&
+			(f.Kind == "" || f.Kind == owner.Kind) &&
+			(f.Name == "" || f.Name == owner.Name) &&
+			(f.UID == "" || f.UID == owner.UID) {
+			return true
+		}
+	}
+	return false
+}
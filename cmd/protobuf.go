@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+)
+
+const protobufContentType = "application/vnd.kubernetes.protobuf"
+
+// resourceListWatcher is the list and watch of dynamic.ResourceInterface used by the watches
+type resourceListWatcher interface {
+	List(opts metav1.ListOptions) (*unstructured.UnstructuredList, error)
+	Watch(opts metav1.ListOptions) (watch.Interface, error)
+}
+
+// protobufResource lists and watches a built-in resource with protobuf (falling back to json if not served),
+// the objects decoded are converted to unstructured as listed and watched by the dynamic client
+type protobufResource struct {
+	client    rest.Interface
+	resource  string
+	namespace string
+	scoped    bool
+}
+
+func newProtobufResource(kubeConfig *rest.Config, mapping *meta.RESTMapping, namespace string) (*protobufResource, error) {
+	if !scheme.Scheme.Recognizes(mapping.GroupVersionKind) {
+		return nil, fmt.Errorf("%s is not a built-in kind", mapping.GroupVersionKind.String())
+	}
+	gv := mapping.GroupVersionKind.GroupVersion()
+	config := rest.CopyConfig(kubeConfig)
+	config.GroupVersion = &gv
+	config.APIPath = "/apis"
+	if gv.Group == "" {
+		config.APIPath = "/api"
+	}
+	config.ContentType = protobufContentType
+	config.AcceptContentTypes = protobufContentType + ",application/json"
+	config.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: scheme.Codecs}
+	client, err := rest.RESTClientFor(config)
+	if err != nil {
+		return nil, err
+	}
+	return &protobufResource{
+		client:    client,
+		resource:  mapping.Resource.Resource,
+		namespace: namespace,
+		scoped:    mapping.Scope.Name() == meta.RESTScopeNameNamespace,
+	}, nil
+}
+
+func (r *protobufResource) List(opts metav1.ListOptions) (*unstructured.UnstructuredList, error) {
+	obj, err := r.client.Get().
+		NamespaceIfScoped(r.namespace, r.scoped && r.namespace != metav1.NamespaceAll).
+		Resource(r.resource).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Do().
+		Get()
+	if err != nil {
+		return nil, err
+	}
+	return toUnstructuredList(obj)
+}
+
+// toUnstructuredList converts a typed list to unstructured, the apiVersion and kind of the items are set later by setTypeMeta
+func toUnstructuredList(obj runtime.Object) (*unstructured.UnstructuredList, error) {
+	listMeta, err := meta.ListAccessor(obj)
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(obj)
+	if err != nil {
+		return nil, err
+	}
+	list := &unstructured.UnstructuredList{Object: map[string]interface{}{}}
+	list.SetResourceVersion(listMeta.GetResourceVersion())
+	list.SetContinue(listMeta.GetContinue())
+	list.Items = make([]unstructured.Unstructured, 0, len(items))
+	for _, item := range items {
+		u, err := toUnstructured(item)
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, *u)
+	}
+	return list, nil
+}
+
+func (r *protobufResource) Watch(opts metav1.ListOptions) (watch.Interface, error) {
+	opts.Watch = true
+	w, err := r.client.Get().
+		NamespaceIfScoped(r.namespace, r.scoped && r.namespace != metav1.NamespaceAll).
+		Resource(r.resource).
+		VersionedParams(&opts, scheme.ParameterCodec).
+		Watch()
+	if err != nil {
+		return nil, err
+	}
+	return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+		if event.Type == watch.Error {
+			// *metav1.Status decoded by the reflector as is
+			return event, true
+		}
+		u, err := toUnstructured(event.Object)
+		if err != nil {
+			return watch.Event{Type: watch.Error, Object: &metav1.Status{
+				Status:  metav1.StatusFailure,
+				Message: fmt.Sprintf("failed to convert %T: %v", event.Object, err),
+			}}, true
+		}
+		event.Object = u
+		return event, true
+	}), nil
+}
+
+// toUnstructured converts a typed object to unstructured, the apiVersion and kind are set later by setTypeMeta
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return u, nil
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
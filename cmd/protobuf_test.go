@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// newPodList returns the encoded list of pods in the media type, as served by apiserver
+func newPodList(b *testing.B, mediaType string, pods int) []byte {
+	list := &corev1.PodList{ListMeta: metav1.ListMeta{ResourceVersion: "1"}}
+	for n := 0; n < pods; n++ {
+		list.Items = append(list.Items, corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            fmt.Sprintf("pod-%d", n),
+				Namespace:       "default",
+				ResourceVersion: "1",
+				Labels:          map[string]string{"app": "example", "pod-template-hash": "5d8f9c7b6"},
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "example-5d8f9c7b6", UID: "6b5f2c1e-8d4a-4f3b-9c2d-1e0f7a6b5c4d"}},
+			},
+			Spec: corev1.PodSpec{
+				NodeName: "node-1",
+				Containers: []corev1.Container{{
+					Name:  "example",
+					Image: "example.com/example:v1",
+					Args:  []string{"--port=8080", "--verbose"},
+					Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}},
+					Env:   []corev1.EnvVar{{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}}},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m"), corev1.ResourceMemory: resource.MustParse("128Mi")},
+					},
+				}},
+			},
+			Status: corev1.PodStatus{
+				Phase:      corev1.PodRunning,
+				PodIP:      "10.0.0.1",
+				Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			},
+		})
+	}
+	info, ok := runtime.SerializerInfoForMediaType(scheme.Codecs.SupportedMediaTypes(), mediaType)
+	if !ok {
+		b.Fatalf("%s not supported", mediaType)
+	}
+	data, err := runtime.Encode(scheme.Codecs.EncoderForVersion(info.Serializer, corev1.SchemeGroupVersion), list)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return data
+}
+
+// BenchmarkDecodePodList compares the decode of the list of pods by the dynamic client (json)
+// and by the watches with Protobuf, both into unstructured objects
+func BenchmarkDecodePodList(b *testing.B) {
+	b.Run("json", func(b *testing.B) {
+		data := newPodList(b, "application/json", 100)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			obj, err := runtime.Decode(unstructured.UnstructuredJSONScheme, data)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if list, ok := obj.(*unstructured.UnstructuredList); !ok || len(list.Items) != 100 {
+				b.Fatalf("decoded %T", obj)
+			}
+		}
+	})
+	b.Run("protobuf", func(b *testing.B) {
+		data := newPodList(b, protobufContentType, 100)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			obj, err := runtime.Decode(scheme.Codecs.UniversalDeserializer(), data)
+			if err != nil {
+				b.Fatal(err)
+			}
+			list, err := toUnstructuredList(obj)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(list.Items) != 100 {
+				b.Fatalf("decoded %d items", len(list.Items))
+			}
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"sync"
+)
+
+//QueuePolicy type
+type QueuePolicy string
+
+const (
+	//QueueBlock constant, the watches wait until the queue is below the limit, so that the events are buffered by the watches instead
+	QueueBlock QueuePolicy = "block"
+	//QueueDropOldest constant, the oldest event waiting in the queue is dropped for the new event
+	QueueDropOldest QueuePolicy = "drop-oldest"
+	//QueueDropNewest constant, the new event is dropped
+	QueueDropNewest QueuePolicy = "drop-newest"
+)
+
+// queueLimit tracks the events waiting in the queue to apply InformerOpts.MaxQueueLength,
+// the retries are not limited as they are delayed by the rate limiter
+type queueLimit struct {
+	max     int
+	policy  QueuePolicy
+	lock    sync.Mutex
+	cond    *sync.Cond
+	seq     uint64
+	pending map[eventKey]uint64
+	order   []queuedKey
+	dropped map[eventKey]bool
+	closed  bool
+}
+
+// queuedKey is the event in order, outdated unless the seq is pending
+type queuedKey struct {
+	eventKey
+	seq uint64
+}
+
+func newQueueLimit(max int, policy QueuePolicy) *queueLimit {
+	l := &queueLimit{
+		max:     max,
+		policy:  policy,
+		pending: map[eventKey]uint64{},
+		dropped: map[eventKey]bool{},
+	}
+	l.cond = sync.NewCond(&l.lock)
+	return l
+}
+
+// add returns the event dropped if the queue is full (the event itself with QueueDropNewest), or blocks with QueueBlock
+func (l *queueLimit) add(key eventKey) (eventKey, bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, ok := l.pending[key]; ok {
+		// coalesced by the queue
+		return key, false
+	}
+	for l.policy == QueueBlock && len(l.pending) >= l.max && !l.closed {
+		l.cond.Wait()
+	}
+	dropped, ok := key, false
+	if len(l.pending) >= l.max && !l.closed {
+		if l.policy == QueueDropNewest {
+			return key, true
+		}
+		if l.policy == QueueDropOldest {
+			dropped, ok = l.dropOldest()
+		}
+	}
+	delete(l.dropped, key)
+	l.seq++
+	l.pending[key] = l.seq
+	l.order = append(l.order, queuedKey{key, l.seq})
+	if len(l.order) > 2*l.max {
+		l.compact()
+	}
+	return dropped, ok
+}
+
+func (l *queueLimit) dropOldest() (eventKey, bool) {
+	for len(l.order) > 0 {
+		oldest := l.order[0]
+		l.order = l.order[1:]
+		if seq, ok := l.pending[oldest.eventKey]; ok && seq == oldest.seq {
+			delete(l.pending, oldest.eventKey)
+			l.dropped[oldest.eventKey] = true
+			return oldest.eventKey, true
+		}
+	}
+	return eventKey{}, false
+}
+
+// compact removes the outdated events from the order
+func (l *queueLimit) compact() {
+	order := make([]queuedKey, 0, len(l.pending))
+	for _, key := range l.order {
+		if seq, ok := l.pending[key.eventKey]; ok && seq == key.seq {
+			order = append(order, key)
+		}
+	}
+	l.order = order
+}
+
+// taken returns true if the event got from the queue is dropped
+func (l *queueLimit) taken(key eventKey) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.dropped[key] {
+		delete(l.dropped, key)
+		return true
+	}
+	if _, ok := l.pending[key]; ok {
+		delete(l.pending, key)
+		l.cond.Signal()
+	}
+	return false
+}
+
+// shutDown releases the watches blocked
+func (l *queueLimit) shutDown() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.closed = true
+	l.cond.Broadcast()
+}
+
+// add adds the event to the queue unless dropped by the limit of the queue
+func (i *informer) add(key eventKey) {
+	if i.queueLimit != nil {
+		if dropped, full := i.queueLimit.add(key); full {
+			if watch, ok := i.watches.get(dropped.watchIndex); ok {
+				i.metrics.queueDropped.WithLabelValues(string(dropped.event), watch.name).Inc()
+			}
+			i.Logger.Info("queue full, dropped event", "event", dropped.event, "key", dropped.key, "policy", i.QueuePolicy)
+			if dropped == key {
+				// the new event, the old object kept for it is released unless kept for the retry of the same event
+				if key.event == EventUpdate && i.queue.NumRequeues(key) == 0 {
+					i.updatedObjects.remove(key.objectKey)
+				}
+				return
+			}
+		}
+	}
+	i.queue.Add(key)
+}
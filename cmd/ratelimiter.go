@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/workqueue"
+)
+
+//DefaultControllerRateLimiter func
+func DefaultControllerRateLimiter() workqueue.RateLimiter {
+	return workqueue.DefaultControllerRateLimiter()
+}
+
+//NewExponentialRateLimiter func
+func NewExponentialRateLimiter(baseDelay time.Duration, maxDelay time.Duration) workqueue.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(baseDelay, maxDelay)
+}
+
+//NewJitterRateLimiter func adds up to jitter (eg. 0.1) of the delays of limiter randomly, limiter is returned if jitter is not positive
+func NewJitterRateLimiter(limiter workqueue.RateLimiter, jitter float64) workqueue.RateLimiter {
+	if jitter <= 0 {
+		return limiter
+	}
+	return &jitterRateLimiter{limiter, jitter}
+}
+
+type jitterRateLimiter struct {
+	workqueue.RateLimiter
+	jitter float64
+}
+
+func (r *jitterRateLimiter) When(item interface{}) time.Duration {
+	return wait.Jitter(r.RateLimiter.When(item), r.jitter)
+}
+
+//ParseRateLimiter parses `default` or `exponential[:baseDelay[:maxDelay]]`
+func ParseRateLimiter(spec string) (workqueue.RateLimiter, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	switch parts[0] {
+	case "default":
+		if len(parts) > 1 {
+			return nil, fmt.Errorf("unexpected backoff options: %s", spec)
+		}
+		return DefaultControllerRateLimiter(), nil
+	case "exponential":
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("unexpected backoff options: %s", spec)
+		}
+		delays := []time.Duration{5 * time.Millisecond, 1000 * time.Second}
+		for index, part := range parts[1:] {
+			delay, err := time.ParseDuration(part)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse backoff delay %q: %v", part, err)
+			}
+			delays[index] = delay
+		}
+		if delays[0] <= 0 || delays[1] < delays[0] {
+			return nil, fmt.Errorf("invalid backoff delays: %s", spec)
+		}
+		return NewExponentialRateLimiter(delays[0], delays[1]), nil
+	}
+	return nil, fmt.Errorf("unknown backoff: %s", spec)
+}
+
+func handlerRateLimiter() workqueue.RateLimiter {
+	if handlerBackoff != "" {
+		if limiter, err := ParseRateLimiter(handlerBackoff); err == nil {
+			return NewJitterRateLimiter(limiter, handlerRetriesJitter)
+		}
+	}
+	return NewJitterRateLimiter(workqueue.NewMaxOfRateLimiter(
+		NewExponentialRateLimiter(handlerRetriesBaseDelay, handlerRetriesMaxDelay),
+		// 10 qps, 100 bucket size.  This is only for retry speed and its only the overall factor (not per item)
+		&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+	), handlerRetriesJitter)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// reconciler holds the dirty objects, each is reconciled at most once per interval
+type reconciler struct {
+	interval time.Duration
+	queue    workqueue.DelayingInterface
+	lock     sync.Mutex
+	dirty    map[objectKey]eventKey
+	last     map[objectKey]time.Time
+}
+
+func newReconciler(interval time.Duration) *reconciler {
+	return &reconciler{
+		interval: interval,
+		queue:    workqueue.NewDelayingQueue(),
+		dirty:    map[objectKey]eventKey{},
+		last:     map[objectKey]time.Time{},
+	}
+}
+
+// add marks the object dirty, it is due once the interval elapsed since last reconciled
+func (r *reconciler) add(key eventKey) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	_, scheduled := r.dirty[key.objectKey]
+	r.dirty[key.objectKey] = key
+	if scheduled {
+		return
+	}
+	delay := time.Duration(0)
+	if last, ok := r.last[key.objectKey]; ok {
+		delay = r.interval - time.Since(last)
+	}
+	r.queue.AddAfter(key.objectKey, delay)
+}
+
+// forget clears the object deleted
+func (r *reconciler) forget(key objectKey) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.dirty, key)
+	delete(r.last, key)
+}
+
+// next blocks until a dirty object is due, returns false once shut down
+func (r *reconciler) next() (eventKey, bool) {
+	for {
+		item, quit := r.queue.Get()
+		if quit {
+			return eventKey{}, false
+		}
+		r.queue.Done(item)
+		if key, ok := r.take(item.(objectKey)); ok {
+			return key, true
+		}
+	}
+}
+
+func (r *reconciler) take(item objectKey) (eventKey, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	key, ok := r.dirty[item]
+	if !ok {
+		// deleted meanwhile
+		return eventKey{}, false
+	}
+	delete(r.dirty, item)
+	r.last[item] = time.Now()
+	key.event = EventReconcile
+	return key, true
+}
+
+func (r *reconciler) shutDown() {
+	r.queue.ShutDown()
+}
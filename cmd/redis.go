@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func handleRedisEvent(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+	if !handlerEvents[event] {
+		return nil
+	}
+	cluster := ClusterFromContext(ctx)
+	stream := &bytes.Buffer{}
+	if err := redisStreamTemplate.Execute(stream, map[string]interface{}{
+		"cluster": cluster,
+		"event":   string(event),
+		"object":  obj.Object,
+	}); err != nil {
+		return PermanentError(fmt.Errorf("failed to render redis stream: %v", err))
+	}
+	object, err := json.Marshal(obj)
+	if err != nil {
+		return PermanentError(fmt.Errorf("failed to marshal object: %v", err))
+	}
+	values := map[string]interface{}{
+		"event":      string(event),
+		"apiVersion": obj.GetAPIVersion(),
+		"kind":       obj.GetKind(),
+		"namespace":  obj.GetNamespace(),
+		"name":       obj.GetName(),
+		"object":     string(object),
+	}
+	if cluster != "" {
+		values["cluster"] = cluster
+	}
+	return redisClient.XAdd(ctx, stream.String(), values)
+}
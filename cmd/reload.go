@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// configWatch is the watch declared in the config file and the indices of the watches added
+type configWatch struct {
+	watchConfig
+	indices []int
+}
+
+// configReloader applies the changes of the watches in the config file to the running informer
+type configReloader struct {
+	informer Informer
+	file     string
+	config   *informerConfig
+	modTime  time.Time
+	watches  []configWatch
+}
+
+func newConfigReloader(informer Informer, file string, config *informerConfig, modTime time.Time) *configReloader {
+	return &configReloader{informer: informer, file: file, config: config, modTime: modTime}
+}
+
+// add adds the watch declared in the config file
+func (r *configReloader) add(watch watchConfig) error {
+	indices, err := addConfigWatch(r.informer, watch)
+	r.watches = append(r.watches, configWatch{watch, indices})
+	return err
+}
+
+func (r *configReloader) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stat, err := os.Stat(r.file)
+			if err != nil {
+				logger.Error("failed to stat config", err, "file", r.file)
+				continue
+			}
+			if stat.ModTime().Equal(r.modTime) {
+				continue
+			}
+			r.modTime = stat.ModTime()
+			r.reload()
+		}
+	}
+}
+
+// reload keeps the running watches if the config is invalid
+func (r *configReloader) reload() {
+	config, err := loadConfig(r.file)
+	if err == nil {
+		err = config.validate()
+	}
+	if err != nil {
+		logger.Error("invalid config, keeping the running config", err, "file", r.file)
+		return
+	}
+	logger.Info("reloading config", "file", r.file)
+	r.apply(config)
+	r.config = config
+
+	running, pending := r.watches, []watchConfig{}
+	watches := []configWatch{}
+	for _, watch := range config.Watches {
+		if index := findConfigWatch(running, watch, false); index >= 0 {
+			watches, running = append(watches, running[index]), append(running[:index:index], running[index+1:]...)
+			continue
+		}
+		pending = append(pending, watch)
+	}
+	for _, watch := range pending {
+		if index := findConfigWatch(running, watch, true); index >= 0 {
+			updated := running[index]
+			running = append(running[:index:index], running[index+1:]...)
+			if err := r.updateSelector(updated, watch.LabelSelector); err != nil {
+				logger.Error("failed to update label selector", err, "watch", watch.String(), "labelSelector", watch.LabelSelector)
+				watches = append(watches, updated)
+				continue
+			}
+			logger.Info("updated label selector", "watch", watch.String(), "labelSelector", watch.LabelSelector, "previous", updated.LabelSelector)
+			updated.LabelSelector = watch.LabelSelector
+			watches = append(watches, updated)
+			continue
+		}
+		indices, err := addConfigWatch(r.informer, watch)
+		if err != nil {
+			logger.Error("failed to watch", err, "watch", watch.String())
+		} else {
+			logger.Info("added watch", "watch", watch.String(), "indices", indices)
+		}
+		watches = append(watches, configWatch{watch, indices})
+	}
+	for _, watch := range running {
+		for _, index := range watch.indices {
+			if err := r.informer.StopWatch(index); err != nil {
+				logger.Error("failed to stop watch", err, "watch", watch.String(), "index", index)
+			}
+		}
+		logger.Info("stopped watch", "watch", watch.String(), "indices", watch.indices)
+	}
+	r.watches = watches
+}
+
+// apply applies the changes of maxRetries and workers to the running informer unless overridden by the flags,
+// the settings removed from the config file keep running as is
+func (r *configReloader) apply(config *informerConfig) {
+	if config.MaxRetries != nil && !reflect.DeepEqual(config.MaxRetries, r.config.MaxRetries) && !configOverrides["max-retries"] {
+		r.informer.SetMaxRetries(*config.MaxRetries)
+		atomic.StoreInt32(&execMaxRetries, int32(*config.MaxRetries))
+		logger.Info("updated maxRetries", "maxRetries", *config.MaxRetries, "file", r.file)
+	}
+	if config.Workers != nil && !reflect.DeepEqual(config.Workers, r.config.Workers) && !configOverrides["workers"] {
+		if err := r.informer.SetWorkers(*config.Workers); err != nil {
+			logger.Error("failed to update workers", err, "workers", *config.Workers, "file", r.file)
+		} else {
+			logger.Info("updated workers", "workers", *config.Workers, "file", r.file)
+		}
+	}
+	if !reflect.DeepEqual(config.SyncTimeout, r.config.SyncTimeout) || !reflect.DeepEqual(config.PartialSync, r.config.PartialSync) {
+		logger.Info("syncTimeout and partialSync are applied on restart", "file", r.file)
+	}
+}
+
+func (r *configReloader) updateSelector(watch configWatch, selector string) error {
+	for _, index := range watch.indices {
+		if err := r.informer.UpdateSelector(index, selector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findConfigWatch returns the index of the watch declared the same (but the label selector if anySelector), or -1
+func findConfigWatch(watches []configWatch, watch watchConfig, anySelector bool) int {
+	for index, running := range watches {
+		declared := running.watchConfig
+		if anySelector {
+			declared.LabelSelector = watch.LabelSelector
+		}
+		if reflect.DeepEqual(declared, watch) {
+			return index
+		}
+	}
+	return -1
+}
+
+// watchIndexer is implemented by the informers returning the indices of the watches added (see informer.watchIndices)
+type watchIndexer interface {
+	watchIndices(apiVersion string, kind string, namespace string, opts WatchOpts) ([]int, error)
+}
+
+// configWatchLock serializes addConfigWatch of the other informers (eg. by the config reloader and the CRD discovery),
+// the watches added are told by the indices not in WatchStatus before
+var configWatchLock sync.Mutex
+
+// addConfigWatch returns the indices of the watches added, even if some kinds failed
+func addConfigWatch(informer Informer, watch watchConfig) ([]int, error) {
+	namespace := watch.Namespace
+	if namespace == "" {
+		namespace = kubeClient.Namespace()
+	}
+	if indexer, ok := informer.(watchIndexer); ok {
+		// not serialized, a watch not synced would block the others
+		return indexer.watchIndices(watch.APIVersion, watch.Kind, namespace, watch.watchOpts())
+	}
+	configWatchLock.Lock()
+	defer configWatchLock.Unlock()
+	added := map[int]bool{}
+	for _, info := range informer.WatchStatus() {
+		added[info.Index] = true
+	}
+	err := informer.Watch(watch.APIVersion, watch.Kind, namespace, watch.watchOpts())
+	indices := []int{}
+	for _, info := range informer.WatchStatus() {
+		if !added[info.Index] {
+			added[info.Index] = true
+			indices = append(indices, info.Index)
+		}
+	}
+	return indices, err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xiaopal/kube-informer/pkg/logging"
+)
+
+// indexingInformer adds a watch of index for each kind, slowly as if waiting for the caches to sync
+type indexingInformer struct {
+	Informer
+	lock    sync.Mutex
+	watches []WatchInfo
+}
+
+func (i *indexingInformer) Watch(apiVersion string, kind string, namespace string, opts WatchOpts) error {
+	return watchKinds(kind, func(kind string) error {
+		time.Sleep(time.Millisecond)
+		i.lock.Lock()
+		defer i.lock.Unlock()
+		i.watches = append(i.watches, WatchInfo{Index: len(i.watches), APIVersion: apiVersion, Kind: kind, Namespace: namespace})
+		return nil
+	})
+}
+
+func (i *indexingInformer) WatchStatus() []WatchInfo {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return append([]WatchInfo{}, i.watches...)
+}
+
+func TestAddConfigWatchConcurrently(t *testing.T) {
+	informer, wg := &indexingInformer{}, sync.WaitGroup{}
+	added := make([][]int, 10)
+	for n := range added {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			watch := watchConfig{APIVersion: "v1", Kind: "ConfigMap,Secret,Service", Namespace: fmt.Sprintf("ns-%d", n)}
+			indices, err := addConfigWatch(informer, watch)
+			if err != nil {
+				t.Error(err)
+			}
+			added[n] = indices
+		}(n)
+	}
+	wg.Wait()
+	status := informer.WatchStatus()
+	for n, indices := range added {
+		if len(indices) != 3 {
+			t.Fatalf("watch %d added indices %v, expected 3", n, indices)
+		}
+		for _, index := range indices {
+			if namespace := status[index].Namespace; namespace != fmt.Sprintf("ns-%d", n) {
+				t.Errorf("watch %d added index %d of namespace %s", n, index, namespace)
+			}
+		}
+	}
+}
+
+func TestAddConfigWatchNotBlockedBySync(t *testing.T) {
+	i := NewInformerWithClients(newTestDynamicClient(t), newTestRESTMapper(), InformerOpts{Logger: logging.NewTextLogger(ioutil.Discard, "test")}).(*informer)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go i.Run(ctx)
+	if err := i.WaitForSync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// the list of the unreachable apiserver fails, never synced without SyncTimeout
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := addConfigWatch(i, watchConfig{APIVersion: "v1", Kind: "ConfigMap", Namespace: "default"})
+		blocked <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	added := make(chan error, 1)
+	go func() {
+		_, err := addConfigWatch(i, watchConfig{APIVersion: "v1", Kind: "Example", Namespace: "default"})
+		added <- err
+	}()
+	select {
+	case err := <-added:
+		if err == nil {
+			t.Error("unknown kind watched")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocked by the watch not synced")
+	}
+	cancel()
+	select {
+	case <-blocked:
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch not synced kept blocking once cancelled")
+	}
+}
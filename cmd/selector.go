@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+)
+
+//UpdateSelector func
+func (i *informer) UpdateSelector(index int, selector string) error {
+	if _, err := labels.Parse(selector); err != nil {
+		return fmt.Errorf("failed to parse label selector: %v", err)
+	}
+	old, ok := i.watches.get(index)
+	if !ok {
+		return fmt.Errorf("watch %d not found", index)
+	}
+	opts := old.opts
+	opts.LabelSelector = selector
+	watch, err := i.newWatch(old.apiVersion, old.kind, old.namespace, opts)
+	if err != nil {
+		return err
+	}
+	running, ok := i.watches.replace(old, watch)
+	if !ok {
+		return fmt.Errorf("watch %d not found", index)
+	}
+	if !running {
+		return nil
+	}
+	// the initial add events of objects unchanged in the previous cache are skipped,
+	// the previous cache is released once synced (or not synced within SyncTimeout)
+	watch.setPrevious(old.watcher.GetStore())
+	defer watch.setPrevious(nil)
+	if old.stop != nil {
+		old.stop()
+	}
+	i.Logger.Info("updated label selector", "watch", watch.name, "index", index, "previous", old.name)
+	i.runWatch(watch)
+	if err := i.waitForCacheSync(watch.ctx, []*informerWatch{watch}); err != nil {
+		return err
+	}
+	if !watch.watcher.HasSynced() {
+		// not synced within SyncTimeout with PartialSync
+		return nil
+	}
+	watch.replayRemoved(old)
+	if i.OnSynced != nil {
+		i.OnSynced(watch.name)
+	}
+	return nil
+}
+
+func (w *informerWatch) setPrevious(previous cache.Store) {
+	w.previousLock.Lock()
+	defer w.previousLock.Unlock()
+	w.previous = previous
+}
+
+// unchanged returns true if the object is cached unchanged by the watch replaced
+func (w *informerWatch) unchanged(key string, obj *unstructured.Unstructured) bool {
+	w.previousLock.RLock()
+	previous := w.previous
+	w.previousLock.RUnlock()
+	if previous == nil {
+		return false
+	}
+	cached, exists, err := previous.GetByKey(key)
+	if err != nil || !exists {
+		return false
+	}
+	u, ok := cached.(*unstructured.Unstructured)
+	return ok && u.GetResourceVersion() == obj.GetResourceVersion()
+}
+
+// replayRemoved enqueues the delete events of the objects cached by the watch replaced but no longer selected,
+// the last known state is the object cached
+func (w *informerWatch) replayRemoved(old *informerWatch) {
+	if w.informer.IgnoreDeletes {
+		return
+	}
+	for _, cached := range old.watcher.GetStore().List() {
+		u, ok := cached.(*unstructured.Unstructured)
+		if !ok {
+			continue
+		}
+		key, err := cache.MetaNamespaceKeyFunc(u)
+		if err != nil {
+			continue
+		}
+		if _, exists, err := w.watcher.GetIndexer().GetByKey(key); err != nil || exists {
+			continue
+		}
+		if w.pushed(EventDelete, key, u, nil, true) {
+			continue
+		}
+		w.informer.deletedObjects.put(objectKey{w.index, key}, u.DeepCopy())
+		w.informer.enqueue(eventKey{objectKey{w.index, key}, EventDelete, true})
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+func serveHTTP(ctx context.Context, addr string, handler http.Handler) {
+	server := &http.Server{Addr: addr, Handler: handler}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		logger.Info("serving http", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("failed to serve http", err, "addr", addr)
+		}
+	}()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+)
+
+//Tracer interface starts the spans around the events handled, see NewOTelTracer built with `-tags otel`
+type Tracer interface {
+	// Start returns ctx with the span started, attributes are eg. event, kind, namespace, name and retries
+	Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span)
+	// Inject adds the trace context of ctx to headers, eg. traceparent of W3C trace context
+	Inject(ctx context.Context, headers map[string]string)
+}
+
+//Span interface
+type Span interface {
+	RecordError(err error)
+	End()
+}
+
+type tracerContextKey struct{}
+
+// startSpan starts the span with the cluster if any, the tracer is passed in ctx for TraceHeaders
+func (i *informer) startSpan(ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	if i.cluster != "" {
+		attributes["cluster"] = i.cluster
+	}
+	ctx, span := i.Tracer.Start(ctx, name, attributes)
+	return context.WithValue(ctx, tracerContextKey{}, i.Tracer), span
+}
+
+//TraceHeaders func returns the headers propagating the trace context of the event passed to Handler, nil if not traced
+func TraceHeaders(ctx context.Context) map[string]string {
+	tracer, ok := ctx.Value(tracerContextKey{}).(Tracer)
+	if !ok {
+		return nil
+	}
+	headers := map[string]string{}
+	tracer.Inject(ctx, headers)
+	return headers
+}
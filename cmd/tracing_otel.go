@@ -0,0 +1,51 @@
+// +build otel
+
+package main
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+//NewOTelTracer func returns the Tracer of the OpenTelemetry tracer provider, the trace context is propagated as W3C traceparent
+func NewOTelTracer(provider trace.TracerProvider) Tracer {
+	return &otelTracer{
+		tracer:     provider.Tracer("github.com/xiaopal/kube-informer"),
+		propagator: propagation.TraceContext{},
+	}
+}
+
+type otelTracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+func (t *otelTracer) Start(ctx context.Context, name string, attributes map[string]string) (context.Context, Span) {
+	attrs := make([]attribute.KeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindConsumer), trace.WithAttributes(attrs...))
+	return ctx, &otelSpan{span}
+}
+
+func (t *otelTracer) Inject(ctx context.Context, headers map[string]string) {
+	t.propagator.Inject(ctx, propagation.MapCarrier(headers))
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *otelSpan) End() {
+	s.span.End()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+//DropManagedFields func is a transform dropping metadata.managedFields
+func DropManagedFields(obj *unstructured.Unstructured) {
+	unstructured.RemoveNestedField(obj.Object, "metadata", "managedFields")
+}
+
+//DropFields func returns a transform dropping the fields of paths, eg. `status` or `metadata.annotations`
+func DropFields(paths ...string) func(obj *unstructured.Unstructured) {
+	fields := [][]string{}
+	for _, path := range paths {
+		if path = strings.TrimSpace(path); path != "" {
+			fields = append(fields, strings.Split(path, "."))
+		}
+	}
+	return func(obj *unstructured.Unstructured) {
+		for _, field := range fields {
+			unstructured.RemoveNestedField(obj.Object, field...)
+		}
+	}
+}
+
+// setTypeMeta returns a transform setting apiVersion and kind of objects if missing, eg. of the items of lists
+func setTypeMeta(apiVersion string, kind string) func(obj *unstructured.Unstructured) {
+	return func(obj *unstructured.Unstructured) {
+		if obj.GetAPIVersion() == "" {
+			obj.SetAPIVersion(apiVersion)
+		}
+		if obj.GetKind() == "" {
+			obj.SetKind(kind)
+		}
+	}
+}
+
+// withTransform transforms the listed and watched objects before they are stored in the cache
+func withTransform(lw *cache.ListWatch, transform func(obj *unstructured.Unstructured)) *cache.ListWatch {
+	if transform == nil {
+		return lw
+	}
+	listFunc, watchFunc := lw.ListFunc, lw.WatchFunc
+	lw.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		list, err := listFunc(options)
+		if err != nil {
+			return nil, err
+		}
+		if list, ok := list.(*unstructured.UnstructuredList); ok {
+			for n := range list.Items {
+				transform(&list.Items[n])
+			}
+		}
+		return list, nil
+	}
+	lw.WatchFunc = func(options metav1.ListOptions) (watch.Interface, error) {
+		w, err := watchFunc(options)
+		if err != nil {
+			return nil, err
+		}
+		return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+			if obj, ok := event.Object.(*unstructured.Unstructured); ok && event.Type != watch.Error {
+				transform(obj)
+			}
+			return event, true
+		}), nil
+	}
+	return lw
+}
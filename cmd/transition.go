@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+//TransitionFilter type matches the events of objects transitioning into the value at the jsonpath (eg. `.status.phase` into `Succeeded`),
+//the add events of objects created with the value and the update events of objects without the value before.
+//Other events are not matched, including reconcile events as the objects before are not retained with InformerOpts.ReconcileInterval.
+type TransitionFilter struct {
+	Path  string `json:"path"`
+	Value string `json:"value"`
+}
+
+// parse returns the jsonpath of the filter, the jsonpath is parsed for each match as it is not safe for concurrent use
+func (f *TransitionFilter) parse() (*jsonpath.JSONPath, error) {
+	path := f.Path
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	j := jsonpath.New("transition").AllowMissingKeys(true)
+	if err := j.Parse(path); err != nil {
+		return nil, fmt.Errorf("failed to parse transition path %q: %v", f.Path, err)
+	}
+	return j, nil
+}
+
+//Validate func
+func (f *TransitionFilter) Validate() error {
+	_, err := f.parse()
+	return err
+}
+
+//Match func
+func (f *TransitionFilter) Match(event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured) (bool, error) {
+	switch event {
+	case EventAdd:
+		return f.matchValue(obj)
+	case EventUpdate:
+		if old == nil {
+			return false, nil
+		}
+		if match, err := f.matchValue(old); err != nil || match {
+			return false, err
+		}
+		return f.matchValue(obj)
+	}
+	return false, nil
+}
+
+// matchValue returns true if any of the results at the path (eg. `.status.containerStatuses[*].state.waiting.reason`) is the value
+func (f *TransitionFilter) matchValue(obj *unstructured.Unstructured) (bool, error) {
+	j, err := f.parse()
+	if err != nil {
+		return false, err
+	}
+	results, err := j.FindResults(obj.Object)
+	if err != nil {
+		return false, err
+	}
+	for _, values := range results {
+		for _, value := range values {
+			if value.IsValid() && value.CanInterface() && fmt.Sprint(value.Interface()) == f.Value {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
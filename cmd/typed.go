@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+//WatchTyped func adds a watch like Informer.Watch with handler of the objects decoded into T (eg. appsv1.Deployment),
+// handler is set as WatchOpts.Handler and called with the deleted object for delete events.
+// The event is retried as failed by the handler if the object can not be decoded.
+func WatchTyped[T any](informer Informer, apiVersion string, kind string, namespace string, opts WatchOpts, handler func(ctx context.Context, event EventType, obj *T, numRetries int) error) error {
+	opts.Handler = func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+		typed := new(T)
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.UnstructuredContent(), typed); err != nil {
+			return fmt.Errorf("failed to decode %s %s/%s: %v", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		}
+		return handler(ctx, event, typed, numRetries)
+	}
+	return informer.Watch(apiVersion, kind, namespace, opts)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+//WatchErrorCategory type
+type WatchErrorCategory string
+
+const (
+	//WatchErrorGone constant, the resourceVersion is too old (410 Gone), the informer relists without intervention
+	WatchErrorGone WatchErrorCategory = "gone"
+	//WatchErrorForbidden constant, the RBAC permissions are missing (403)
+	WatchErrorForbidden WatchErrorCategory = "forbidden"
+	//WatchErrorUnauthorized constant, the credentials are invalid or expired (401)
+	WatchErrorUnauthorized WatchErrorCategory = "unauthorized"
+	//WatchErrorNotFound constant, the resource is not served (404), eg. the CRD is deleted
+	WatchErrorNotFound WatchErrorCategory = "notfound"
+	//WatchErrorTransient constant, other errors (eg. network errors and 5xx) expected to recover on retry
+	WatchErrorTransient WatchErrorCategory = "transient"
+)
+
+//ClassifyWatchError func
+func ClassifyWatchError(err error) WatchErrorCategory {
+	switch {
+	case apierrors.IsGone(err) || apierrors.IsResourceExpired(err):
+		return WatchErrorGone
+	case apierrors.IsForbidden(err):
+		return WatchErrorForbidden
+	case apierrors.IsUnauthorized(err):
+		return WatchErrorUnauthorized
+	case apierrors.IsNotFound(err):
+		return WatchErrorNotFound
+	}
+	return WatchErrorTransient
+}
+
+// withWatchErrors calls onError with the errors of list and watch requests and the error events of watches (eg. 410 Gone),
+// the reflector relists or rewatches after these errors
+func withWatchErrors(lw *cache.ListWatch, onList func(), onError func(op string, err error)) *cache.ListWatch {
+	listFunc, watchFunc := lw.ListFunc, lw.WatchFunc
+	lw.ListFunc = func(options metav1.ListOptions) (runtime.Object, error) {
+		onList()
+		list, err := listFunc(options)
+		if err != nil {
+			onError("list", err)
+			return nil, err
+		}
+		return list, nil
+	}
+	lw.WatchFunc = func(options metav1.ListOptions) (watch.Interface, error) {
+		w, err := watchFunc(options)
+		if err != nil {
+			onError("watch", err)
+			return nil, err
+		}
+		return watch.Filter(w, func(event watch.Event) (watch.Event, bool) {
+			if event.Type == watch.Error {
+				onError("watch", apierrors.FromObject(event.Object))
+			}
+			return event, true
+		}), nil
+	}
+	return lw
+}
+
+func (w *informerWatch) listed() {
+	w.informer.metrics.lists.WithLabelValues(w.name).Inc()
+}
+
+func (w *informerWatch) watchError(op string, err error) {
+	category := ClassifyWatchError(err)
+	w.informer.metrics.watchErrors.WithLabelValues(w.name, op, string(category)).Inc()
+	if w.informer.OnWatchError != nil {
+		w.informer.OnWatchError(w.name, category, err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// withWatchTimeout sets the timeout of the watch requests, so that apiserver closes the watches periodically.
+// The reflector rewatches from the last resourceVersion if a watch is closed normally,
+// so the watches closed end with an expired error instead to relist from apiserver.
+func withWatchTimeout(lw *cache.ListWatch, timeout time.Duration) *cache.ListWatch {
+	if timeout <= 0 {
+		return lw
+	}
+	watchFunc := lw.WatchFunc
+	lw.WatchFunc = func(options metav1.ListOptions) (watch.Interface, error) {
+		timeoutSeconds := int64(timeout.Seconds())
+		if timeoutSeconds < 1 {
+			timeoutSeconds = 1
+		}
+		options.TimeoutSeconds = &timeoutSeconds
+		w, err := watchFunc(options)
+		if err != nil {
+			return nil, err
+		}
+		return newRelistWatch(w), nil
+	}
+	return lw
+}
+
+// relistWatch passes the events of the watch, and an expired error once the watch is closed (unless stopped)
+type relistWatch struct {
+	watch.Interface
+	result   chan watch.Event
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+func newRelistWatch(w watch.Interface) *relistWatch {
+	r := &relistWatch{
+		Interface: w,
+		result:    make(chan watch.Event),
+		stopped:   make(chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+func (r *relistWatch) run() {
+	defer close(r.result)
+	for event := range r.Interface.ResultChan() {
+		select {
+		case r.result <- event:
+		case <-r.stopped:
+			return
+		}
+	}
+	select {
+	case r.result <- watch.Event{Type: watch.Error, Object: &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Code:    http.StatusGone,
+		Reason:  metav1.StatusReasonExpired,
+		Message: "watch timed out, relisting",
+	}}:
+	case <-r.stopped:
+	}
+}
+
+func (r *relistWatch) ResultChan() <-chan watch.Event {
+	return r.result
+}
+
+func (r *relistWatch) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stopped)
+		r.Interface.Stop()
+	})
+}
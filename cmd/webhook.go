@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+
+	"github.com/xiaopal/kube-informer/pkg/webhook"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type webhookEvent struct {
+	Cluster   string                     `json:"cluster,omitempty"`
+	Event     EventType                  `json:"event"`
+	Object    *unstructured.Unstructured `json:"object"`
+	OldObject *unstructured.Unstructured `json:"oldObject,omitempty"`
+	Retries   int                        `json:"retries"`
+	Synced    bool                       `json:"synced"`
+	// LastAppliedDiff is present on update events with --diff-last-applied
+	LastAppliedDiff *ConfigDiff `json:"lastAppliedDiff,omitempty"`
+}
+
+func handleWebhookEvent(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+	if !handlerEvents[event] {
+		return nil
+	}
+	if headers := TraceHeaders(ctx); headers != nil {
+		ctx = webhook.WithHeaders(ctx, headers)
+	}
+	err := webhookClient.Post(ctx, &webhookEvent{
+		Cluster:         ClusterFromContext(ctx),
+		Event:           event,
+		Object:          obj,
+		OldObject:       old,
+		Retries:         numRetries,
+		Synced:          synced,
+		LastAppliedDiff: LastAppliedDiffFromContext(ctx),
+	})
+	if webhook.IsClientError(err) {
+		// the request will not succeed by retrying
+		return PermanentError(err)
+	}
+	if statusErr, ok := err.(*webhook.StatusError); ok && statusErr.RetryAfter > 0 {
+		return RetryAfterError(err, statusErr.RetryAfter)
+	}
+	return err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// workerPool is the workers running, resized by SetWorkers
+type workerPool struct {
+	ctx     context.Context
+	items   chan eventKey
+	stop    <-chan struct{}
+	workers *sync.WaitGroup
+	// removed is closed to remove the worker, by the order workers started
+	removed []chan struct{}
+}
+
+// startWorkers starts the workers until stop is closed, the number of workers is changed by SetWorkers meanwhile
+func (i *informer) startWorkers(ctx context.Context, items chan eventKey, stop <-chan struct{}, workers *sync.WaitGroup) {
+	i.workersLock.Lock()
+	defer i.workersLock.Unlock()
+	i.pool = &workerPool{ctx: ctx, items: items, stop: stop, workers: workers}
+	for n := 0; n < int(atomic.LoadInt32(&i.workers)); n++ {
+		i.addWorker(n)
+	}
+}
+
+// stopWorkers stops resizing the workers before they are stopped
+func (i *informer) stopWorkers() {
+	i.workersLock.Lock()
+	defer i.workersLock.Unlock()
+	i.pool = nil
+}
+
+// addWorker starts the worker n of the pool, it handles the events until the pool is stopped or the worker is removed
+func (i *informer) addWorker(n int) {
+	p, removed := i.pool, make(chan struct{})
+	p.removed = append(p.removed, removed)
+	processNext, idle := i.processNextItem, func() bool {
+		return true
+	}
+	if i.fifo != nil {
+		queue := i.fifo[n]
+		processNext = func(ctx context.Context) bool {
+			return i.processNextEvent(ctx, queue)
+		}
+	} else if p.items != nil {
+		waiting := []eventKey{}
+		processNext = func(ctx context.Context) bool {
+			return i.processNextBatch(ctx, p.items, &waiting)
+		}
+		// the events waiting for the objects of the last batch are not left to other workers
+		idle = func() bool {
+			return len(waiting) == 0
+		}
+	}
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-removed:
+		case <-p.stop:
+		}
+		close(stop)
+	}()
+	p.workers.Add(1)
+	atomic.AddInt32(&i.liveWorkers, 1)
+	go func() {
+		defer p.workers.Done()
+		defer atomic.AddInt32(&i.liveWorkers, -1)
+		wait.Until(func() {
+			for processNext(p.ctx) {
+				select {
+				case <-removed:
+					if idle() {
+						return
+					}
+				default:
+				}
+			}
+		}, time.Second, stop)
+	}()
+}
+
+func (i *informer) SetWorkers(workers int) error {
+	if workers < 1 {
+		workers = 1
+	}
+	if i.fifo != nil {
+		return fmt.Errorf("workers can't be changed with NoCoalesce")
+	}
+	i.workersLock.Lock()
+	defer i.workersLock.Unlock()
+	atomic.StoreInt32(&i.workers, int32(workers))
+	if i.pool == nil {
+		// applied by Run
+		return nil
+	}
+	for n := len(i.pool.removed); n < workers; n++ {
+		i.addWorker(n)
+	}
+	for n := len(i.pool.removed); n > workers; n-- {
+		close(i.pool.removed[n-1])
+		i.pool.removed = i.pool.removed[:n-1]
+	}
+	return nil
+}
+
+func (i *informer) SetMaxRetries(maxRetries int) {
+	atomic.StoreInt32(&i.maxRetries, int32(maxRetries))
+}
+
+// getMaxRetries returns MaxRetries, changed by SetMaxRetries
+func (i *informer) getMaxRetries() int {
+	return int(atomic.LoadInt32(&i.maxRetries))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSetWorkersWhileRunning(t *testing.T) {
+	running, release := make(chan string, 10), make(chan struct{})
+	i, w := newTestInformer(InformerOpts{
+		Workers: 1,
+		Handler: func(ctx context.Context, event EventType, obj *unstructured.Unstructured, old *unstructured.Unstructured, numRetries int, synced bool) error {
+			running <- obj.GetName()
+			<-release
+			return nil
+		},
+	})
+	objs := []*unstructured.Unstructured{}
+	for n := 0; n < 4; n++ {
+		objs = append(objs, newConfigMap(fmt.Sprintf("cm-%d", n), "1"))
+	}
+	fakeWatch(w, objs...)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go i.Run(ctx)
+	expectRunning := func(handlers int) {
+		for n := 0; n < handlers; n++ {
+			select {
+			case <-running:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("%d handlers running, expected %d", n, handlers)
+			}
+		}
+		select {
+		case <-running:
+			t.Fatalf("more than %d handlers running", handlers)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	expectRunning(1)
+	if err := i.SetWorkers(3); err != nil {
+		t.Fatal(err)
+	}
+	expectRunning(2)
+	if err := i.SetWorkers(1); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	expectRunning(1)
+	// the workers removed exit after the events in progress
+	for deadline := time.Now().Add(5 * time.Second); atomic.LoadInt32(&i.liveWorkers) != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d workers running, expected 1", atomic.LoadInt32(&i.liveWorkers))
+		}
+	}
+	if !i.Healthy() {
+		t.Error("not healthy with the workers removed")
+	}
+}
+
+func TestSetWorkersNoCoalesce(t *testing.T) {
+	i, _ := newTestInformer(InformerOpts{Workers: 2, NoCoalesce: true})
+	if err := i.SetWorkers(4); err == nil {
+		t.Error("workers changed with NoCoalesce")
+	}
+}
+
+func TestSetMaxRetries(t *testing.T) {
+	i, w := newTestInformer(InformerOpts{MaxRetries: 5})
+	e, err := &queuedEvent{watch: w, numRetries: 3}, errors.New("failed")
+	if !i.retryable(e, err) {
+		t.Error("not retried within MaxRetries")
+	}
+	i.SetMaxRetries(3)
+	if i.retryable(e, err) {
+		t.Error("retried beyond MaxRetries changed")
+	}
+}
@@ -42,12 +42,12 @@ func Start() Interface {
 	signal.Notify(interruptChan, syscall.SIGINT, syscall.SIGTERM)
 	ctx, endCtx := context.WithCancel(context.Background())
 	wg := &sync.WaitGroup{}
+	wg.Add(1)
 	go func() {
-		wg.Add(1)
 		defer wg.Done()
 		select {
 		case sig := <-interruptChan:
-			logger.Printf("signal %v", sig)
+			logger.Printf("signal %v, shutting down", sig)
 			endCtx()
 		case <-ctx.Done():
 		}
@@ -0,0 +1,159 @@
+package filesink
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/spf13/pflag"
+)
+
+//ClientOpts options
+type ClientOpts struct {
+	Path string
+	// MaxBytes rotates the file before it exceeds the size, 0 to disable
+	MaxBytes int64
+	// MaxFiles is the number of rotated files retained as Path.1 (the latest) to Path.<MaxFiles>
+	MaxFiles int
+}
+
+//Client interface
+type Client interface {
+	BindFlags(flags *pflag.FlagSet, envPrefix string)
+	Validate() error
+	Append(data []byte) error
+	Close() error
+}
+
+//NewClient func
+func NewClient(opts *ClientOpts) Client {
+	return &client{ClientOpts: *opts}
+}
+
+type client struct {
+	ClientOpts
+	lock sync.Mutex
+	file *os.File
+	size int64
+}
+
+//BindFlags func
+func (c *client) BindFlags(flags *pflag.FlagSet, envPrefix string) {
+	if c.Path == "" {
+		c.Path = os.Getenv(envPrefix + "FILE_PATH")
+	}
+	if c.MaxBytes == 0 {
+		c.MaxBytes = 100 * 1024 * 1024
+		if v, err := strconv.ParseInt(os.Getenv(envPrefix+"FILE_MAX_BYTES"), 10, 64); err == nil {
+			c.MaxBytes = v
+		}
+	}
+	if c.MaxFiles == 0 {
+		c.MaxFiles = 5
+		if v, err := strconv.Atoi(os.Getenv(envPrefix + "FILE_MAX_FILES")); err == nil {
+			c.MaxFiles = v
+		}
+	}
+	flags.StringVar(&c.Path, "file-path", c.Path, "file to append events to as json lines")
+	flags.Int64Var(&c.MaxBytes, "file-max-bytes", c.MaxBytes, "rotate the file before it exceeds the size in bytes, 0 to disable")
+	flags.IntVar(&c.MaxFiles, "file-max-files", c.MaxFiles, "number of rotated files retained")
+}
+
+//Validate func
+func (c *client) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("--file-path required")
+	}
+	if c.MaxBytes < 0 || c.MaxFiles < 0 {
+		return fmt.Errorf("--file-max-bytes and --file-max-files must not be negative")
+	}
+	return nil
+}
+
+//Append func writes data as a line and syncs the file, so that the lines appended are not lost on crash
+func (c *client) Append(data []byte) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	line := append(append(make([]byte, 0, len(data)+1), data...), '\n')
+	if err := c.open(int64(len(line))); err != nil {
+		return err
+	}
+	n, err := c.file.Write(line)
+	c.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", c.Path, err)
+	}
+	if err := c.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync %s: %v", c.Path, err)
+	}
+	return nil
+}
+
+// open opens the file, rotated first unless the line fits
+func (c *client) open(size int64) error {
+	if c.file != nil && c.MaxBytes > 0 && c.size > 0 && c.size+size > c.MaxBytes {
+		if err := c.file.Close(); err != nil {
+			return fmt.Errorf("failed to close %s: %v", c.Path, err)
+		}
+		c.file = nil
+		if err := c.rotate(); err != nil {
+			return err
+		}
+	}
+	if c.file != nil {
+		return nil
+	}
+	file, err := os.OpenFile(c.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", c.Path, err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat %s: %v", c.Path, err)
+	}
+	c.file, c.size = file, stat.Size()
+	if c.MaxBytes > 0 && c.size > 0 && c.size+size > c.MaxBytes {
+		// appended before restart
+		return c.open(size)
+	}
+	return nil
+}
+
+// rotate renames the file to Path.1 and the rotated files to the next, the oldest beyond MaxFiles is removed
+func (c *client) rotate() error {
+	if c.MaxFiles < 1 {
+		if err := os.Remove(c.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", c.Path, err)
+		}
+		return nil
+	}
+	rotated := func(index int) string {
+		return fmt.Sprintf("%s.%d", c.Path, index)
+	}
+	if err := os.Remove(rotated(c.MaxFiles)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %v", rotated(c.MaxFiles), err)
+	}
+	for index := c.MaxFiles - 1; index > 0; index-- {
+		if err := os.Rename(rotated(index), rotated(index+1)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate %s: %v", rotated(index), err)
+		}
+	}
+	if err := os.Rename(c.Path, rotated(1)); err != nil {
+		return fmt.Errorf("failed to rotate %s: %v", c.Path, err)
+	}
+	return nil
+}
+
+//Close func
+func (c *client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.file == nil {
+		return nil
+	}
+	err := c.file.Close()
+	c.file = nil
+	return err
+}
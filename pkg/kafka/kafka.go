@@ -0,0 +1,145 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+//ClientOpts options
+type ClientOpts struct {
+	Brokers        []string
+	Topic          string
+	Acks           string
+	Async          bool
+	FlushFrequency time.Duration
+}
+
+//Client interface
+type Client interface {
+	BindFlags(flags *pflag.FlagSet, envPrefix string)
+	Validate() error
+	Send(ctx context.Context, key string, value []byte) error
+	Close() error
+}
+
+type producer interface {
+	Send(ctx context.Context, key string, value []byte) error
+	Close() error
+}
+
+type headersContextKey struct{}
+
+//WithHeaders func returns ctx with the headers of the messages sent with ctx, eg. to propagate the trace context
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	return context.WithValue(ctx, headersContextKey{}, headers)
+}
+
+func headersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(headersContextKey{}).(map[string]string)
+	return headers
+}
+
+// newProducer is only available when built with `-tags kafka`
+var newProducer func(opts *ClientOpts) (producer, error)
+
+//NewClient func
+func NewClient(opts *ClientOpts) Client {
+	return &client{ClientOpts: *opts}
+}
+
+type client struct {
+	ClientOpts
+	lock     sync.Mutex
+	producer producer
+}
+
+//BindFlags func
+func (c *client) BindFlags(flags *pflag.FlagSet, envPrefix string) {
+	if len(c.Brokers) == 0 {
+		c.Brokers = strings.Fields(os.Getenv(envPrefix + "KAFKA_BROKERS"))
+	}
+	if c.Topic == "" {
+		c.Topic = os.Getenv(envPrefix + "KAFKA_TOPIC")
+	}
+	if c.Acks == "" {
+		if c.Acks = os.Getenv(envPrefix + "KAFKA_ACKS"); c.Acks == "" {
+			c.Acks = "all"
+		}
+	}
+	if !c.Async {
+		c.Async = os.Getenv(envPrefix+"KAFKA_ASYNC") != ""
+	}
+	if c.FlushFrequency <= 0 {
+		c.FlushFrequency = 100 * time.Millisecond
+		if frequency, err := time.ParseDuration(os.Getenv(envPrefix + "KAFKA_FLUSH_FREQUENCY")); err == nil {
+			c.FlushFrequency = frequency
+		}
+	}
+	flags.StringSliceVar(&c.Brokers, "kafka-brokers", c.Brokers, "kafka brokers to publish events to, eg. `localhost:9092`")
+	flags.StringVar(&c.Topic, "kafka-topic", c.Topic, "kafka topic to publish events to")
+	flags.StringVar(&c.Acks, "kafka-acks", c.Acks, "kafka acks, `all`, `leader` or `none`")
+	flags.BoolVar(&c.Async, "kafka-async", c.Async, "publish events of concurrent workers in batches, each event still waits for its delivery")
+	flags.DurationVar(&c.FlushFrequency, "kafka-flush-frequency", c.FlushFrequency, "kafka async: max time to batch events")
+}
+
+//Validate func
+func (c *client) Validate() error {
+	if newProducer == nil {
+		return fmt.Errorf("kafka not supported, rebuild with -tags kafka")
+	}
+	if len(c.Brokers) == 0 {
+		return fmt.Errorf("--kafka-brokers required")
+	}
+	if c.Topic == "" {
+		return fmt.Errorf("--kafka-topic required")
+	}
+	switch c.Acks {
+	case "all", "leader", "none":
+	default:
+		return fmt.Errorf("unknown kafka acks %q", c.Acks)
+	}
+	return nil
+}
+
+//Send func, the producer is connected on first send
+func (c *client) Send(ctx context.Context, key string, value []byte) error {
+	p, err := c.getProducer()
+	if err != nil {
+		return fmt.Errorf("failed to connect kafka: %v", err)
+	}
+	if err := p.Send(ctx, key, value); err != nil {
+		return fmt.Errorf("failed to publish to kafka: %v", err)
+	}
+	return nil
+}
+
+func (c *client) getProducer() (producer, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.producer == nil {
+		p, err := newProducer(&c.ClientOpts)
+		if err != nil {
+			return nil, err
+		}
+		c.producer = p
+	}
+	return c.producer, nil
+}
+
+//Close func
+func (c *client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.producer == nil {
+		return nil
+	}
+	err := c.producer.Close()
+	c.producer = nil
+	return err
+}
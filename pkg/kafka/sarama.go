@@ -0,0 +1,120 @@
+// +build kafka
+
+package kafka
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Shopify/sarama"
+)
+
+func init() {
+	newProducer = newSaramaProducer
+}
+
+func newSaramaProducer(opts *ClientOpts) (producer, error) {
+	config := sarama.NewConfig()
+	switch opts.Acks {
+	case "leader":
+		config.Producer.RequiredAcks = sarama.WaitForLocal
+	case "none":
+		config.Producer.RequiredAcks = sarama.NoResponse
+	default:
+		config.Producer.RequiredAcks = sarama.WaitForAll
+	}
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	if !opts.Async {
+		p, err := sarama.NewSyncProducer(opts.Brokers, config)
+		if err != nil {
+			return nil, err
+		}
+		return &syncProducer{p, opts.Topic}, nil
+	}
+	config.Producer.Flush.Frequency = opts.FlushFrequency
+	p, err := sarama.NewAsyncProducer(opts.Brokers, config)
+	if err != nil {
+		return nil, err
+	}
+	ap := &asyncProducer{producer: p, topic: opts.Topic}
+	ap.wg.Add(1)
+	go ap.run()
+	return ap, nil
+}
+
+func newMessage(ctx context.Context, topic string, key string, value []byte) *sarama.ProducerMessage {
+	msg := &sarama.ProducerMessage{Topic: topic, Value: sarama.ByteEncoder(value)}
+	if key != "" {
+		msg.Key = sarama.StringEncoder(key)
+	}
+	for name, value := range headersFromContext(ctx) {
+		msg.Headers = append(msg.Headers, sarama.RecordHeader{Key: []byte(name), Value: []byte(value)})
+	}
+	return msg
+}
+
+type syncProducer struct {
+	producer sarama.SyncProducer
+	topic    string
+}
+
+func (p *syncProducer) Send(ctx context.Context, key string, value []byte) error {
+	_, _, err := p.producer.SendMessage(newMessage(ctx, p.topic, key, value))
+	return err
+}
+
+func (p *syncProducer) Close() error {
+	return p.producer.Close()
+}
+
+type asyncProducer struct {
+	producer sarama.AsyncProducer
+	topic    string
+	wg       sync.WaitGroup
+}
+
+// run calls back the senders with the delivery results
+func (p *asyncProducer) run() {
+	defer p.wg.Done()
+	successes, errors := p.producer.Successes(), p.producer.Errors()
+	for successes != nil || errors != nil {
+		select {
+		case msg, ok := <-successes:
+			if !ok {
+				successes = nil
+				continue
+			}
+			msg.Metadata.(chan error) <- nil
+		case err, ok := <-errors:
+			if !ok {
+				errors = nil
+				continue
+			}
+			err.Msg.Metadata.(chan error) <- err.Err
+		}
+	}
+}
+
+func (p *asyncProducer) Send(ctx context.Context, key string, value []byte) error {
+	result := make(chan error, 1)
+	msg := newMessage(ctx, p.topic, key, value)
+	msg.Metadata = result
+	select {
+	case p.producer.Input() <- msg:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *asyncProducer) Close() error {
+	p.producer.AsyncClose()
+	p.wg.Wait()
+	return nil
+}
@@ -1,6 +1,7 @@
 package kubeclient
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -22,12 +23,21 @@ type ClientOpts struct {
 	Namespace            string
 	AllNamespaces        bool
 	DisableAllNamespaces bool
+	// Impersonate and ImpersonateGroups are the user and groups to act as
+	Impersonate          string
+	ImpersonateGroups    []string
+	CertificateAuthority string
+	ClientCertificate    string
+	ClientKey            string
+	Insecure             bool
 }
 
 //Client interface
 type Client interface {
 	BindFlags(flags *pflag.FlagSet, envPrefix string)
+	Validate() error
 	GetConfig() (*rest.Config, error)
+	GetContextConfig(context string) (*rest.Config, error)
 	Namespace() string
 	DefaultNamespace() string
 }
@@ -60,17 +70,72 @@ func (c *client) BindFlags(flags *pflag.FlagSet, envPrefix string) {
 	}
 	flags.StringVar(&c.KubeConfigPath, "kubeconfig", c.KubeConfigPath, "path to the kubeconfig file")
 	flags.StringVarP(&c.MasterURL, "server", "s", os.Getenv(envPrefix+"SERVER"), "URL of the Kubernetes API server")
-	flags.StringVarP(&c.ClientOpts.Namespace, "namespace", "n", os.Getenv(envPrefix+"NAMESPACE"), "namespace")
+	flags.StringVarP(&c.ClientOpts.Namespace, "namespace", "n", os.Getenv(envPrefix+"NAMESPACE"), "namespace, or a comma separated list of namespaces")
 	if !c.DisableAllNamespaces {
 		flags.BoolVar(&c.AllNamespaces, "all-namespaces", os.Getenv(envPrefix+"ALL_NAMESPACES") != "", "all namespaces")
 	}
+	if c.Impersonate == "" {
+		c.Impersonate = os.Getenv(envPrefix + "AS")
+	}
+	if len(c.ImpersonateGroups) == 0 {
+		c.ImpersonateGroups = strings.Fields(os.Getenv(envPrefix + "AS_GROUP"))
+	}
+	if c.CertificateAuthority == "" {
+		c.CertificateAuthority = os.Getenv(envPrefix + "CERTIFICATE_AUTHORITY")
+	}
+	if c.ClientCertificate == "" {
+		c.ClientCertificate = os.Getenv(envPrefix + "CLIENT_CERTIFICATE")
+	}
+	if c.ClientKey == "" {
+		c.ClientKey = os.Getenv(envPrefix + "CLIENT_KEY")
+	}
+	if !c.Insecure {
+		c.Insecure = os.Getenv(envPrefix+"INSECURE_SKIP_TLS_VERIFY") != ""
+	}
+	flags.StringVar(&c.Impersonate, "as", c.Impersonate, "username to impersonate for the requests")
+	flags.StringArrayVar(&c.ImpersonateGroups, "as-group", c.ImpersonateGroups, "group to impersonate for the requests, requires --as")
+	flags.StringVar(&c.CertificateAuthority, "certificate-authority", c.CertificateAuthority, "path to a cert file for the certificate authority")
+	flags.StringVar(&c.ClientCertificate, "client-certificate", c.ClientCertificate, "path to a client certificate file for TLS")
+	flags.StringVar(&c.ClientKey, "client-key", c.ClientKey, "path to a client key file for TLS")
+	flags.BoolVar(&c.Insecure, "insecure-skip-tls-verify", c.Insecure, "skip the validity check of the server's certificate, insecure")
+}
+
+//Validate func
+func (c *client) Validate() error {
+	if c.Insecure && c.CertificateAuthority != "" {
+		return fmt.Errorf("--certificate-authority and --insecure-skip-tls-verify are mutually exclusive")
+	}
+	if (c.ClientCertificate == "") != (c.ClientKey == "") {
+		return fmt.Errorf("--client-certificate and --client-key must be given together")
+	}
+	if len(c.ImpersonateGroups) > 0 && c.Impersonate == "" {
+		return fmt.Errorf("--as-group requires --as")
+	}
+	return nil
+}
+
+// overrides of the kubeconfig, the server is only overridden for the current context
+func (c *client) overrides(server string) *clientcmd.ConfigOverrides {
+	return &clientcmd.ConfigOverrides{
+		ClusterInfo: clientcmdapi.Cluster{
+			Server:                server,
+			CertificateAuthority:  c.CertificateAuthority,
+			InsecureSkipTLSVerify: c.Insecure,
+		},
+		AuthInfo: clientcmdapi.AuthInfo{
+			ClientCertificate: c.ClientCertificate,
+			ClientKey:         c.ClientKey,
+			Impersonate:       c.Impersonate,
+			ImpersonateGroups: c.ImpersonateGroups,
+		},
+	}
 }
 
 func (c *client) ensure() {
 	c.once.Do(func() {
 		c.clientConfig = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
 			&clientcmd.ClientConfigLoadingRules{ExplicitPath: c.KubeConfigPath},
-			&clientcmd.ConfigOverrides{ClusterInfo: clientcmdapi.Cluster{Server: c.MasterURL}})
+			c.overrides(c.MasterURL))
 	})
 }
 
@@ -101,3 +166,11 @@ func (c *client) GetConfig() (*rest.Config, error) {
 	c.ensure()
 	return c.clientConfig.ClientConfig()
 }
+
+func (c *client) GetContextConfig(context string) (*rest.Config, error) {
+	overrides := c.overrides("")
+	overrides.CurrentContext = context
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		&clientcmd.ClientConfigLoadingRules{ExplicitPath: c.KubeConfigPath},
+		overrides).ClientConfig()
+}
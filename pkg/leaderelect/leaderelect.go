@@ -27,6 +27,7 @@ type HelperOpts struct {
 	ResourceLock         string
 	LockObjectName       string
 	LockObjectNamespace  string
+	Identity             string
 	GetConfigFunc        func() (*rest.Config, error)
 	DefaultNamespaceFunc func() string
 }
@@ -71,6 +72,7 @@ func (h *helper) BindFlags(flags *pflag.FlagSet, envPrefix string) {
 	}
 	flags.StringVar(&h.LockObjectName, "leader-elect", os.Getenv(envPrefix+"LEADER_ELECT"), "leader election: [endpoints|configmaps/]<object name>")
 	flags.StringVar(&h.LockObjectNamespace, "leader-elect-namespace", os.Getenv(envPrefix+"LEADER_ELECT_NAMESPACE"), "leader election: object namespace")
+	flags.StringVar(&h.Identity, "leader-elect-identity", os.Getenv(envPrefix+"LEADER_ELECT_IDENTITY"), "leader election: holder identity (default <hostname>_<uuid>)")
 	flags.DurationVar(&h.LeaseDuration, "leader-elect-lease", h.LeaseDuration, "leader election: lease duration")
 	flags.DurationVar(&h.RenewDeadline, "leader-elect-renew", h.RenewDeadline, "leader election: renew deadline")
 	flags.DurationVar(&h.RetryPeriod, "leader-elect-retry", h.RetryPeriod, "leader election: retry period")
@@ -111,11 +113,14 @@ func (h *helper) Run(ctx context.Context, handler func(context.Context)) {
 	if err != nil {
 		logger.Fatalf("failed to get CoreV1 Client: %v", err)
 	}
-	hostname, err := os.Hostname()
-	if err != nil {
-		logger.Fatalf("failed to get hostname: %v", err)
+	id := strings.TrimSpace(h.Identity)
+	if id == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			logger.Fatalf("failed to get hostname: %v", err)
+		}
+		id = hostname + "_" + string(uuid.NewUUID())
 	}
-	id := hostname + "_" + string(uuid.NewUUID())
 	broadcaster := record.NewBroadcaster()
 	lock, err := resourcelock.New(
 		h.ResourceLock,
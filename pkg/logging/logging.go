@@ -0,0 +1,113 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Logger interface, keysAndValues are pairs of field name and value
+type Logger interface {
+	Info(msg string, keysAndValues ...interface{})
+	Error(msg string, err error, keysAndValues ...interface{})
+}
+
+//New func returns a `text` or `json` logger
+func New(format string, out io.Writer, name string) (Logger, error) {
+	switch format {
+	case "", "text":
+		return NewTextLogger(out, name), nil
+	case "json":
+		return NewJSONLogger(out, name), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q", format)
+}
+
+//NewTextLogger func
+func NewTextLogger(out io.Writer, name string) Logger {
+	return &textLogger{log.New(out, fmt.Sprintf("[%s] ", name), log.Flags())}
+}
+
+type textLogger struct {
+	logger *log.Logger
+}
+
+func (l *textLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Print(msg + formatText(keysAndValues))
+}
+
+func (l *textLogger) Error(msg string, err error, keysAndValues ...interface{}) {
+	l.logger.Print(fmt.Sprintf("%s: %v", msg, err) + formatText(keysAndValues))
+}
+
+func formatText(keysAndValues []interface{}) string {
+	buf := &bytes.Buffer{}
+	for n := 0; n < len(keysAndValues); n += 2 {
+		key, value := fmt.Sprint(keysAndValues[n]), interface{}(nil)
+		if n+1 < len(keysAndValues) {
+			value = keysAndValues[n+1]
+		}
+		s := fmt.Sprint(value)
+		if s == "" || strings.ContainsAny(s, " \t\r\n\"=") {
+			s = strconv.Quote(s)
+		}
+		fmt.Fprintf(buf, " %s=%s", key, s)
+	}
+	return buf.String()
+}
+
+//NewJSONLogger func writes one json object per line
+func NewJSONLogger(out io.Writer, name string) Logger {
+	return &jsonLogger{out: out, name: name}
+}
+
+type jsonLogger struct {
+	lock sync.Mutex
+	out  io.Writer
+	name string
+}
+
+func (l *jsonLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.write("info", msg, nil, keysAndValues)
+}
+
+func (l *jsonLogger) Error(msg string, err error, keysAndValues ...interface{}) {
+	l.write("error", msg, err, keysAndValues)
+}
+
+func (l *jsonLogger) write(level string, msg string, err error, keysAndValues []interface{}) {
+	entry := map[string]interface{}{}
+	for n := 0; n < len(keysAndValues); n += 2 {
+		key, value := fmt.Sprint(keysAndValues[n]), interface{}(nil)
+		if n+1 < len(keysAndValues) {
+			value = keysAndValues[n+1]
+		}
+		if e, ok := value.(error); ok {
+			value = e.Error()
+		}
+		entry[key] = value
+	}
+	entry["time"], entry["level"], entry["logger"], entry["msg"] = time.Now().Format(time.RFC3339Nano), level, l.name, msg
+	if err != nil {
+		entry["error"] = err.Error()
+	}
+	line, e := json.Marshal(entry)
+	if e != nil {
+		line, _ = json.Marshal(map[string]interface{}{
+			"time":   entry["time"],
+			"level":  level,
+			"logger": l.name,
+			"msg":    msg,
+			"error":  fmt.Sprintf("failed to marshal log entry: %v", e),
+		})
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.out.Write(append(line, '\n'))
+}
@@ -0,0 +1,128 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/spf13/pflag"
+)
+
+//ClientOpts options
+type ClientOpts struct {
+	URL       string
+	Creds     string
+	TLSCA     string
+	TLSCert   string
+	TLSKey    string
+	JetStream bool
+}
+
+//Client interface
+type Client interface {
+	BindFlags(flags *pflag.FlagSet, envPrefix string)
+	Validate() error
+	Publish(ctx context.Context, subject string, data []byte) error
+	Close() error
+}
+
+type conn interface {
+	Publish(ctx context.Context, subject string, data []byte) error
+	Close() error
+}
+
+// connect is only available when built with `-tags nats`
+var connect func(opts *ClientOpts) (conn, error)
+
+//NewClient func
+func NewClient(opts *ClientOpts) Client {
+	return &client{ClientOpts: *opts}
+}
+
+type client struct {
+	ClientOpts
+	lock sync.Mutex
+	conn conn
+}
+
+//BindFlags func
+func (c *client) BindFlags(flags *pflag.FlagSet, envPrefix string) {
+	if c.URL == "" {
+		if c.URL = os.Getenv(envPrefix + "NATS_URL"); c.URL == "" {
+			c.URL = "nats://127.0.0.1:4222"
+		}
+	}
+	if c.Creds == "" {
+		c.Creds = os.Getenv(envPrefix + "NATS_CREDS")
+	}
+	if c.TLSCA == "" {
+		c.TLSCA = os.Getenv(envPrefix + "NATS_TLS_CA")
+	}
+	if c.TLSCert == "" {
+		c.TLSCert = os.Getenv(envPrefix + "NATS_TLS_CERT")
+	}
+	if c.TLSKey == "" {
+		c.TLSKey = os.Getenv(envPrefix + "NATS_TLS_KEY")
+	}
+	if !c.JetStream {
+		c.JetStream = os.Getenv(envPrefix+"NATS_JETSTREAM") != ""
+	}
+	flags.StringVar(&c.URL, "nats-url", c.URL, "nats server urls (comma separated) to publish events to")
+	flags.StringVar(&c.Creds, "nats-creds", c.Creds, "nats user credentials file")
+	flags.StringVar(&c.TLSCA, "nats-tls-ca", c.TLSCA, "nats tls: ca file to verify the server")
+	flags.StringVar(&c.TLSCert, "nats-tls-cert", c.TLSCert, "nats tls: client cert file")
+	flags.StringVar(&c.TLSKey, "nats-tls-key", c.TLSKey, "nats tls: client key file")
+	flags.BoolVar(&c.JetStream, "nats-jetstream", c.JetStream, "publish to jetstream and wait for the ack of the stream")
+}
+
+//Validate func
+func (c *client) Validate() error {
+	if connect == nil {
+		return fmt.Errorf("nats not supported, rebuild with -tags nats")
+	}
+	if c.URL == "" {
+		return fmt.Errorf("--nats-url required")
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("--nats-tls-cert and --nats-tls-key must be given together")
+	}
+	return nil
+}
+
+//Publish func, the connection is established on first publish and reconnected automatically
+func (c *client) Publish(ctx context.Context, subject string, data []byte) error {
+	conn, err := c.getConn()
+	if err != nil {
+		return fmt.Errorf("failed to connect nats: %v", err)
+	}
+	if err := conn.Publish(ctx, subject, data); err != nil {
+		return fmt.Errorf("failed to publish to nats: %v", err)
+	}
+	return nil
+}
+
+func (c *client) getConn() (conn, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		conn, err := connect(&c.ClientOpts)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	return c.conn, nil
+}
+
+//Close func
+func (c *client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,62 @@
+// +build nats
+
+package nats
+
+import (
+	"context"
+
+	natsgo "github.com/nats-io/nats.go"
+)
+
+func init() {
+	connect = connectNATS
+}
+
+type natsConn struct {
+	conn      *natsgo.Conn
+	jetStream natsgo.JetStreamContext
+}
+
+func connectNATS(opts *ClientOpts) (conn, error) {
+	options := []natsgo.Option{
+		natsgo.Name("kube-informer"),
+		natsgo.MaxReconnects(-1),
+	}
+	if opts.Creds != "" {
+		options = append(options, natsgo.UserCredentials(opts.Creds))
+	}
+	if opts.TLSCA != "" {
+		options = append(options, natsgo.RootCAs(opts.TLSCA))
+	}
+	if opts.TLSCert != "" {
+		options = append(options, natsgo.ClientCert(opts.TLSCert, opts.TLSKey))
+	}
+	nc, err := natsgo.Connect(opts.URL, options...)
+	if err != nil {
+		return nil, err
+	}
+	c := &natsConn{conn: nc}
+	if opts.JetStream {
+		if c.jetStream, err = nc.JetStream(); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Publish waits for the ack of jetstream, or the flush of the connection without jetstream
+func (c *natsConn) Publish(ctx context.Context, subject string, data []byte) error {
+	if c.jetStream != nil {
+		_, err := c.jetStream.Publish(subject, data, natsgo.Context(ctx))
+		return err
+	}
+	if err := c.conn.Publish(subject, data); err != nil {
+		return err
+	}
+	return c.conn.FlushWithContext(ctx)
+}
+
+func (c *natsConn) Close() error {
+	return c.conn.Drain()
+}
@@ -0,0 +1,139 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/spf13/pflag"
+)
+
+//ClientOpts options
+type ClientOpts struct {
+	URL      string
+	Password string
+	TLS      bool
+	TLSCA    string
+	TLSCert  string
+	TLSKey   string
+	MaxLen   int64
+}
+
+//Client interface
+type Client interface {
+	BindFlags(flags *pflag.FlagSet, envPrefix string)
+	Validate() error
+	XAdd(ctx context.Context, stream string, values map[string]interface{}) error
+	Close() error
+}
+
+type conn interface {
+	XAdd(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) error
+	Close() error
+}
+
+// connect is only available when built with `-tags redis`
+var connect func(opts *ClientOpts) (conn, error)
+
+//NewClient func
+func NewClient(opts *ClientOpts) Client {
+	return &client{ClientOpts: *opts}
+}
+
+type client struct {
+	ClientOpts
+	lock sync.Mutex
+	conn conn
+}
+
+//BindFlags func
+func (c *client) BindFlags(flags *pflag.FlagSet, envPrefix string) {
+	if c.URL == "" {
+		if c.URL = os.Getenv(envPrefix + "REDIS_URL"); c.URL == "" {
+			c.URL = "redis://127.0.0.1:6379/0"
+		}
+	}
+	if c.Password == "" {
+		c.Password = os.Getenv(envPrefix + "REDIS_PASSWORD")
+	}
+	if !c.TLS {
+		c.TLS = os.Getenv(envPrefix+"REDIS_TLS") != ""
+	}
+	if c.TLSCA == "" {
+		c.TLSCA = os.Getenv(envPrefix + "REDIS_TLS_CA")
+	}
+	if c.TLSCert == "" {
+		c.TLSCert = os.Getenv(envPrefix + "REDIS_TLS_CERT")
+	}
+	if c.TLSKey == "" {
+		c.TLSKey = os.Getenv(envPrefix + "REDIS_TLS_KEY")
+	}
+	if c.MaxLen == 0 {
+		if maxLen, err := strconv.ParseInt(os.Getenv(envPrefix+"REDIS_MAXLEN"), 10, 64); err == nil {
+			c.MaxLen = maxLen
+		}
+	}
+	flags.StringVar(&c.URL, "redis-url", c.URL, "redis url to add events to, eg. `redis://user@host:6379/0` or `rediss://` for tls")
+	flags.StringVar(&c.Password, "redis-password", c.Password, "redis password, overrides the password of the url")
+	flags.BoolVar(&c.TLS, "redis-tls", c.TLS, "redis tls, implied by rediss:// urls")
+	flags.StringVar(&c.TLSCA, "redis-tls-ca", c.TLSCA, "redis tls: ca file to verify the server")
+	flags.StringVar(&c.TLSCert, "redis-tls-cert", c.TLSCert, "redis tls: client cert file")
+	flags.StringVar(&c.TLSKey, "redis-tls-key", c.TLSKey, "redis tls: client key file")
+	flags.Int64Var(&c.MaxLen, "redis-maxlen", c.MaxLen, "trim the stream to about the length (MAXLEN ~) on each add, 0 for unlimited")
+}
+
+//Validate func
+func (c *client) Validate() error {
+	if connect == nil {
+		return fmt.Errorf("redis not supported, rebuild with -tags redis")
+	}
+	if c.URL == "" {
+		return fmt.Errorf("--redis-url required")
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("--redis-tls-cert and --redis-tls-key must be given together")
+	}
+	if c.MaxLen < 0 {
+		return fmt.Errorf("--redis-maxlen must not be negative")
+	}
+	return nil
+}
+
+//XAdd func adds an entry of the values to the stream, the connection is established on first add
+func (c *client) XAdd(ctx context.Context, stream string, values map[string]interface{}) error {
+	conn, err := c.getConn()
+	if err != nil {
+		return fmt.Errorf("failed to connect redis: %v", err)
+	}
+	if err := conn.XAdd(ctx, stream, c.MaxLen, values); err != nil {
+		return fmt.Errorf("failed to add to redis stream %s: %v", stream, err)
+	}
+	return nil
+}
+
+func (c *client) getConn() (conn, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		conn, err := connect(&c.ClientOpts)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+	return c.conn, nil
+}
+
+//Close func
+func (c *client) Close() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,66 @@
+// +build redis
+
+package redis
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestXAdd(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.RequireAuth("secret")
+	// the password of the url is overridden
+	client := NewClient(&ClientOpts{URL: fmt.Sprintf("redis://:wrong@%s/0", server.Addr()), Password: "secret", MaxLen: 2})
+	defer client.Close()
+	if err := client.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < 3; n++ {
+		if err := client.XAdd(context.Background(), "events", map[string]interface{}{"name": fmt.Sprintf("cm-%d", n), "event": "add"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := server.Stream("events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// trimmed to the last entries by MAXLEN
+	if len(entries) != 2 {
+		t.Fatalf("%d entries in the stream, expected 2", len(entries))
+	}
+	if expected := []string{"event", "add", "name", "cm-2"}; !reflect.DeepEqual(entries[1].Values, expected) {
+		t.Errorf("added %v, expected %v", entries[1].Values, expected)
+	}
+}
+
+func TestXAddErrors(t *testing.T) {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	server.RequireAuth("secret")
+	if err := server.Set("events", "not a stream"); err != nil {
+		t.Fatal(err)
+	}
+	for _, opts := range []ClientOpts{
+		{URL: fmt.Sprintf("redis://%s/0", server.Addr())},
+		{URL: fmt.Sprintf("redis://%s/0", server.Addr()), Password: "secret"},
+	} {
+		client := NewClient(&opts)
+		// the command errors are returned to retry the events
+		if err := client.XAdd(context.Background(), "events", map[string]interface{}{"event": "add"}); err == nil {
+			t.Errorf("added to %s", opts.URL)
+		}
+		client.Close()
+	}
+}
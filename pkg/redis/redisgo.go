@@ -0,0 +1,104 @@
+// +build redis
+
+package redis
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+func init() {
+	connect = connectRedis
+}
+
+type redisConn struct {
+	pool *redigo.Pool
+}
+
+func connectRedis(opts *ClientOpts) (conn, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "redis" && u.Scheme != "rediss" {
+		return nil, fmt.Errorf("invalid redis url scheme: %s", u.Scheme)
+	}
+	if opts.Password != "" {
+		username := ""
+		if u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				username = u.User.Username()
+			}
+		}
+		u.User = url.UserPassword(username, opts.Password)
+	}
+	options := []redigo.DialOption{}
+	if opts.TLS || opts.TLSCA != "" || opts.TLSCert != "" {
+		// the tls of rediss:// urls is set by the scheme
+		u.Scheme = "rediss"
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if opts.TLSCA != "" {
+			ca, err := ioutil.ReadFile(opts.TLSCA)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no certificates found in %s", opts.TLSCA)
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if opts.TLSCert != "" {
+			cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		options = append(options, redigo.DialTLSConfig(tlsConfig))
+	}
+	rawurl := u.String()
+	return &redisConn{pool: &redigo.Pool{
+		MaxIdle:     16,
+		IdleTimeout: 5 * time.Minute,
+		DialContext: func(ctx context.Context) (redigo.Conn, error) {
+			return redigo.DialURLContext(ctx, rawurl, options...)
+		},
+	}}, nil
+}
+
+// XAdd trims the stream approximately (MAXLEN ~) if maxLen > 0
+func (c *redisConn) XAdd(ctx context.Context, stream string, maxLen int64, values map[string]interface{}) error {
+	conn, err := c.pool.GetContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	args := redigo.Args{stream}
+	if maxLen > 0 {
+		args = args.Add("MAXLEN", "~", maxLen)
+	}
+	args = args.Add("*")
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		args = args.Add(field, values[field])
+	}
+	_, err = redigo.DoContext(conn, ctx, "XADD", args...)
+	return err
+}
+
+func (c *redisConn) Close() error {
+	return c.pool.Close()
+}
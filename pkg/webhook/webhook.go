@@ -0,0 +1,181 @@
+package webhook
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+//ClientOpts options
+type ClientOpts struct {
+	URL     string
+	Headers []string
+	Timeout time.Duration
+	// GzipThreshold compresses the request bodies of at least the size in bytes with Content-Encoding: gzip, 0 to disable.
+	// The webhook must accept gzip request bodies, it is not negotiated.
+	GzipThreshold int
+}
+
+//Client interface
+type Client interface {
+	BindFlags(flags *pflag.FlagSet, envPrefix string)
+	Validate() error
+	Post(ctx context.Context, payload interface{}) error
+}
+
+//StatusError type
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	// RetryAfter is the delay of the Retry-After header (eg. of 429 or 503), 0 if absent
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("webhook responded %s", e.Status)
+	}
+	return fmt.Sprintf("webhook responded %s: %s", e.Status, e.Body)
+}
+
+//IsClientError func returns true for the 4xx errors not to retry, 408 Request Timeout and 429 Too Many Requests are not
+func IsClientError(err error) bool {
+	statusErr, ok := err.(*StatusError)
+	return ok && statusErr.StatusCode >= 400 && statusErr.StatusCode < 500 &&
+		statusErr.StatusCode != http.StatusRequestTimeout && statusErr.StatusCode != http.StatusTooManyRequests
+}
+
+// retryAfter parses the Retry-After header of seconds or http date, 0 if absent or invalid
+func retryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(strings.TrimSpace(header)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && time.Until(date) > 0 {
+		return time.Until(date)
+	}
+	return 0
+}
+
+type headersContextKey struct{}
+
+//WithHeaders func returns ctx with the headers added to the requests posted with ctx, eg. to propagate the trace context
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	return context.WithValue(ctx, headersContextKey{}, headers)
+}
+
+//NewClient func
+func NewClient(opts *ClientOpts) Client {
+	return &client{ClientOpts: *opts}
+}
+
+type client struct {
+	ClientOpts
+}
+
+//BindFlags func
+func (c *client) BindFlags(flags *pflag.FlagSet, envPrefix string) {
+	if c.URL == "" {
+		c.URL = os.Getenv(envPrefix + "URL")
+	}
+	if envHeaders := os.Getenv(envPrefix + "HEADER"); envHeaders != "" && len(c.Headers) == 0 {
+		c.Headers = strings.Split(envHeaders, "\n")
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 30 * time.Second
+		if timeout, err := time.ParseDuration(os.Getenv(envPrefix + "WEBHOOK_TIMEOUT")); err == nil {
+			c.Timeout = timeout
+		}
+	}
+	if c.GzipThreshold == 0 {
+		if threshold, err := strconv.Atoi(os.Getenv(envPrefix + "WEBHOOK_GZIP_THRESHOLD")); err == nil {
+			c.GzipThreshold = threshold
+		}
+	}
+	flags.StringVar(&c.URL, "url", c.URL, "webhook url to post events to")
+	flags.StringArrayVar(&c.Headers, "header", c.Headers, "webhook request header, eg. `Authorization: Bearer xxx`")
+	flags.DurationVar(&c.Timeout, "webhook-timeout", c.Timeout, "webhook request timeout")
+	flags.IntVar(&c.GzipThreshold, "webhook-gzip-threshold", c.GzipThreshold, "gzip webhook request bodies of at least the size in bytes (the webhook must accept Content-Encoding: gzip), 0 to disable")
+}
+
+//Validate func
+func (c *client) Validate() error {
+	if c.URL == "" {
+		return fmt.Errorf("--url required")
+	}
+	for _, header := range c.Headers {
+		if kv := strings.SplitN(header, ":", 2); len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return fmt.Errorf("invalid header %q", header)
+		}
+	}
+	if c.GzipThreshold < 0 {
+		return fmt.Errorf("--webhook-gzip-threshold must not be negative")
+	}
+	return nil
+}
+
+//Post func
+func (c *client) Post(ctx context.Context, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	gzipped := c.GzipThreshold > 0 && len(body) >= c.GzipThreshold
+	if gzipped {
+		if body, err = gzipBody(body); err != nil {
+			return fmt.Errorf("failed to gzip payload: %v", err)
+		}
+	}
+	// Content-Length is set by the length of the reader
+	req, err := http.NewRequest(http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if gzipped {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for _, header := range c.Headers {
+		if kv := strings.SplitN(header, ":", 2); len(kv) == 2 {
+			req.Header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	}
+	if headers, ok := ctx.Value(headersContextKey{}).(map[string]string); ok {
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+	}
+	resp, err := (&http.Client{Timeout: c.Timeout}).Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &StatusError{StatusCode: resp.StatusCode, Status: resp.Status, Body: strings.TrimSpace(string(respBody)), RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	}
+	return nil
+}
+
+func gzipBody(body []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	if _, err := w.Write(body); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPostStatusErrors(t *testing.T) {
+	for _, test := range []struct {
+		status      int
+		retryAfter  string
+		clientError bool
+		delay       time.Duration
+	}{
+		{http.StatusBadRequest, "", true, 0},
+		{http.StatusNotFound, "", true, 0},
+		{http.StatusRequestTimeout, "", false, 0},
+		{http.StatusTooManyRequests, "3", false, 3 * time.Second},
+		{http.StatusServiceUnavailable, "120", false, 2 * time.Minute},
+		{http.StatusInternalServerError, "invalid", false, 0},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if test.retryAfter != "" {
+				w.Header().Set("Retry-After", test.retryAfter)
+			}
+			w.WriteHeader(test.status)
+		}))
+		err := NewClient(&ClientOpts{URL: server.URL, Timeout: time.Second}).Post(context.Background(), map[string]string{"event": "add"})
+		server.Close()
+		statusErr, ok := err.(*StatusError)
+		if !ok {
+			t.Fatalf("%d: expected StatusError, got %v", test.status, err)
+		}
+		if IsClientError(err) != test.clientError {
+			t.Errorf("%d: IsClientError is %v, expected %v", test.status, !test.clientError, test.clientError)
+		}
+		if statusErr.RetryAfter != test.delay {
+			t.Errorf("%d: RetryAfter is %v, expected %v", test.status, statusErr.RetryAfter, test.delay)
+		}
+	}
+}
+
+func TestRetryAfterDate(t *testing.T) {
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if delay := retryAfter(date); delay <= 50*time.Second || delay > time.Minute {
+		t.Errorf("Retry-After %s parsed as %v", date, delay)
+	}
+	if delay := retryAfter(time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)); delay != 0 {
+		t.Errorf("Retry-After in the past parsed as %v", delay)
+	}
+}
+
+func TestPostGzip(t *testing.T) {
+	small, large := map[string]string{"event": "add"}, map[string]string{"event": "add", "object": strings.Repeat("x", 1024)}
+	for _, payload := range []map[string]string{small, large} {
+		received := map[string]string{}
+		var encoding string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding = r.Header.Get("Content-Encoding")
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				t.Error(err)
+			}
+			if int64(len(body)) != r.ContentLength {
+				t.Errorf("read %d bytes, Content-Length is %d", len(body), r.ContentLength)
+			}
+			if encoding == "gzip" {
+				reader, err := gzip.NewReader(bytes.NewReader(body))
+				if err == nil {
+					body, err = ioutil.ReadAll(reader)
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			if err := json.Unmarshal(body, &received); err != nil {
+				t.Error(err)
+			}
+		}))
+		err := NewClient(&ClientOpts{URL: server.URL, Timeout: time.Second, GzipThreshold: 512}).Post(context.Background(), payload)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gzipped := len(payload) == len(large); (encoding == "gzip") != gzipped {
+			t.Errorf("Content-Encoding %q of %d fields, gzipped expected %v", encoding, len(payload), gzipped)
+		}
+		if !reflect.DeepEqual(received, payload) {
+			t.Errorf("received %v, expected %v", received, payload)
+		}
+	}
+}